@@ -2,6 +2,11 @@
 
 package config
 
+import (
+	"os"
+	"time"
+)
+
 var Config = config{
 	DB: DBConfig{
 		DSN: "root:root@tcp(localhost:13316)/webook?charset=utf8mb4&parseTime=True&loc=Local",
@@ -9,4 +14,91 @@ var Config = config{
 	Redis: RedisConfig{
 		Addr: "localhost:6379",
 	},
+	JWT: JWTConfig{
+		AccessKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": "k6CswdUm77WKcbM68UQUuxVsHSpTCwgK",
+			},
+		},
+		RefreshKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": "k6CswdUm77WKcbM68UQUuxVsHSpTCwgA",
+			},
+		},
+		StateKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": "uX6}oS1`eP0:jY0-oI9:oE4^wD2;tLs@",
+			},
+		},
+		Binding: BindingConfig{
+			UserAgent:    "warn",
+			IP:           "off",
+			IPv4MaskBits: 24,
+			IPv6MaskBits: 64,
+		},
+	},
+	SMS: SMSConfig{
+		Providers:        []string{"local"},
+		Failover:         "round_robin",
+		TimeoutThreshold: 3,
+		Timeout:          time.Second * 3,
+		// 密钥不写在代码里, 本地调回调的时候自己设置环境变量
+		CallbackToken: os.Getenv("WEBOOK_SMS_CALLBACK_TOKEN"),
+		Tencent: TencentSMSConfig{
+			Region: "ap-nanjing",
+			Templates: map[string]string{
+				"verify_code": "1877556",
+			},
+		},
+		Aliyun: AliyunSMSConfig{
+			Templates: map[string]AliyunSMSTemplate{
+				"verify_code": {Code: "SMS_000000000", Params: []string{"code"}},
+			},
+		},
+		Limit: SMSLimitConfig{
+			PhonePerDay:      10,
+			IPPerHour:        20,
+			CaptchaIPPerHour: 5,
+			GlobalPerSecond:  10,
+		},
+	},
+	RateLimit: []RateLimitRuleConfig{
+		{
+			Name:      "global",
+			Routes:    []string{"/**"},
+			Key:       "ip",
+			Algorithm: "token_bucket",
+			Window:    time.Second,
+			Threshold: 100,
+		},
+		{
+			// 登录注册防止撞库
+			Name:      "login",
+			Routes:    []string{"POST /users/login", "POST /users/login_sms", "POST /users/login_email", "POST /users/signup"},
+			Key:       "ip",
+			Algorithm: "sliding_window",
+			Window:    time.Minute,
+			Threshold: 10,
+		},
+		{
+			// 发验证码还有按手机号和 IP 的限流, 这里先挡住刷接口的
+			Name:      "code_send",
+			Routes:    []string{"POST /users/*/code/send", "POST /users/*/*/code/send"},
+			Key:       "ip",
+			Algorithm: "fixed_window",
+			Window:    time.Minute,
+			Threshold: 5,
+		},
+		{
+			Name:      "profile",
+			Routes:    []string{"GET /users/profile"},
+			Key:       "uid",
+			Algorithm: "token_bucket",
+			Window:    time.Second,
+			Threshold: 20,
+		},
+	},
 }
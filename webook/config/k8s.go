@@ -2,11 +2,109 @@
 
 package config
 
+import (
+	"os"
+	"time"
+)
+
 var Config = config{
+	Server: ServerConfig{
+		// 集群里的 ingress 会把真实 IP 追加到 X-Forwarded-For 后面
+		TrustedProxies:  []string{"10.0.0.0/8"},
+		RemoteIPHeaders: []string{"X-Forwarded-For"},
+	},
 	DB: DBConfig{
 		DSN: "root:root@tcp(webook-mysql:11309)/webook?charset=utf8mb4&parseTime=True&loc=Local",
 	},
 	Redis: RedisConfig{
 		Addr: "webook-redis:11379",
 	},
+	// 密钥都来自 webook-secret, 见 k8s-webook-deployment.yaml, 没有配置启动的时候直接报错
+	JWT: JWTConfig{
+		AccessKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": os.Getenv("WEBOOK_JWT_ACCESS_KEY"),
+			},
+		},
+		RefreshKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": os.Getenv("WEBOOK_JWT_REFRESH_KEY"),
+			},
+		},
+		StateKeys: KeyRingConfig{
+			Active: "v1",
+			Keys: map[string]string{
+				"v1": os.Getenv("WEBOOK_JWT_STATE_KEY"),
+			},
+		},
+		Binding: BindingConfig{
+			UserAgent:    "warn",
+			IP:           "off",
+			IPv4MaskBits: 24,
+			IPv6MaskBits: 64,
+		},
+	},
+	SMS: SMSConfig{
+		Providers:        []string{"local"},
+		Failover:         "round_robin",
+		TimeoutThreshold: 3,
+		Timeout:          time.Second * 3,
+		// 来自 webook-secret, 见 k8s-webook-deployment.yaml
+		CallbackToken: os.Getenv("WEBOOK_SMS_CALLBACK_TOKEN"),
+		Tencent: TencentSMSConfig{
+			Region: "ap-nanjing",
+			Templates: map[string]string{
+				"verify_code": "1877556",
+			},
+		},
+		Aliyun: AliyunSMSConfig{
+			Templates: map[string]AliyunSMSTemplate{
+				"verify_code": {Code: "SMS_000000000", Params: []string{"code"}},
+			},
+		},
+		Limit: SMSLimitConfig{
+			PhonePerDay:      10,
+			IPPerHour:        20,
+			CaptchaIPPerHour: 5,
+			GlobalPerSecond:  10,
+		},
+	},
+	RateLimit: []RateLimitRuleConfig{
+		{
+			Name:      "global",
+			Routes:    []string{"/**"},
+			Key:       "ip",
+			Algorithm: "token_bucket",
+			Window:    time.Second,
+			Threshold: 100,
+		},
+		{
+			// 登录注册防止撞库
+			Name:      "login",
+			Routes:    []string{"POST /users/login", "POST /users/login_sms", "POST /users/login_email", "POST /users/signup"},
+			Key:       "ip",
+			Algorithm: "sliding_window",
+			Window:    time.Minute,
+			Threshold: 10,
+		},
+		{
+			// 发验证码还有按手机号和 IP 的限流, 这里先挡住刷接口的
+			Name:      "code_send",
+			Routes:    []string{"POST /users/*/code/send", "POST /users/*/*/code/send"},
+			Key:       "ip",
+			Algorithm: "fixed_window",
+			Window:    time.Minute,
+			Threshold: 5,
+		},
+		{
+			Name:      "profile",
+			Routes:    []string{"GET /users/profile"},
+			Key:       "uid",
+			Algorithm: "token_bucket",
+			Window:    time.Second,
+			Threshold: 20,
+		},
+	},
 }
@@ -1,8 +1,25 @@
 package config
 
+import "time"
+
 type config struct {
-	DB    DBConfig
-	Redis RedisConfig
+	Server ServerConfig
+	DB     DBConfig
+	Redis  RedisConfig
+	JWT    JWTConfig
+	Email  EmailConfig
+	SMS    SMSConfig
+	// RateLimit 接口限流规则, 一个请求匹配上的所有规则都要检查
+	RateLimit []RateLimitRuleConfig
+}
+
+// ServerConfig 按 IP 限流、验证码和 token 绑定都靠客户端 IP, 所以只能信任自己的代理设置的请求头
+type ServerConfig struct {
+	// TrustedProxies 前面的负载均衡或者 ingress 的 IP 或网段, 只有从这些地址来的请求才看 RemoteIPHeaders,
+	// 为空就只认 TCP 连接的对端地址, 客户端自己带的 X-Forwarded-For 不算数
+	TrustedProxies []string
+	// RemoteIPHeaders 代理设置真实 IP 的请求头, 为空就用 gin 默认的 X-Forwarded-For 和 X-Real-IP
+	RemoteIPHeaders []string
 }
 
 type DBConfig struct {
@@ -12,3 +29,103 @@ type DBConfig struct {
 type RedisConfig struct {
 	Addr string
 }
+
+// EmailConfig SMTP 发信配置, Addr 为空就只打日志不真的发
+type EmailConfig struct {
+	// Addr host:port
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMSConfig 只需要填用到的服务商的配置
+type SMSConfig struct {
+	// Providers 按顺序排列的服务商, 可以是 tencent, aliyun 或者 local, 为空就是 local
+	Providers []string
+	// Failover 多个服务商之间怎么切换:
+	// round_robin 轮询, 失败了马上换下一个; timeout 一直用排在前面的, 失败了这一次临时用后面的顶上,
+	// 连续超时 TimeoutThreshold 次才换, TimeoutThreshold 要大于 0
+	Failover         string
+	TimeoutThreshold int32
+	// Timeout 单个服务商一次发送的超时时间, 为空就用默认值
+	Timeout time.Duration
+	// CallbackToken 服务商回调送达状态的地址上带的 token, 为空就不接收回调
+	CallbackToken string
+	Tencent       TencentSMSConfig
+	Aliyun        AliyunSMSConfig
+	Limit         SMSLimitConfig
+}
+
+// SMSLimitConfig 发短信的几层限流, 0 表示这一层不限
+type SMSLimitConfig struct {
+	PhonePerDay int
+	IPPerHour   int
+	// CaptchaIPPerHour 同一个 IP 一小时内超过这个数, 要先过图形验证码才能发, 应该比 IPPerHour 小
+	CaptchaIPPerHour int
+	GlobalPerSecond  int
+}
+
+type RateLimitRuleConfig struct {
+	Name string
+	// Routes 写法见 route.Rule, 比如 "POST /users/login"
+	Routes []string
+	// Key 是 ip, uid 或者 header:请求头的名字
+	Key string
+	// Algorithm 是 sliding_window, fixed_window 或者 token_bucket,
+	// 令牌桶的容量是 Threshold, 每个 Window 补满
+	Algorithm string
+	Window    time.Duration
+	Threshold int
+}
+
+type TencentSMSConfig struct {
+	SecretId  string
+	SecretKey string
+	Region    string
+	AppId     string
+	SignName  string
+	// Templates 逻辑模板 id, 比如 verify_code -> 腾讯云的模板 id
+	Templates map[string]string
+}
+
+type AliyunSMSConfig struct {
+	AccessKeyId     string
+	AccessKeySecret string
+	SignName        string
+	// Endpoint 为空就用阿里云的默认地址
+	Endpoint  string
+	Templates map[string]AliyunSMSTemplate
+}
+
+// AliyunSMSTemplate 阿里云的模板参数有名字, Params 按顺序对应验证码等参数
+type AliyunSMSTemplate struct {
+	Code   string
+	Params []string
+}
+
+type JWTConfig struct {
+	// AccessKeys 短 token, RefreshKeys 长 token, StateKeys 微信登录的 state
+	AccessKeys  KeyRingConfig
+	RefreshKeys KeyRingConfig
+	StateKeys   KeyRingConfig
+	Binding     BindingConfig
+}
+
+// BindingConfig token 和客户端的绑定检查, 模式是 off, warn, reject
+type BindingConfig struct {
+	UserAgent    string
+	IP           string
+	IPv4MaskBits int
+	IPv6MaskBits int
+}
+
+// KeyRingConfig 轮换密钥: 先加新 key 并改 Active, 旧 token 过期之后再删掉旧 key
+type KeyRingConfig struct {
+	// Algorithm 为空或者 HS512 用共享密钥, RS256 和 EdDSA 用私钥签名,
+	// 公钥通过 /.well-known/jwks.json 发布
+	Algorithm string
+	Active    string
+	// Keys kid -> secret, 非对称算法的时候是 PEM 格式的私钥
+	Keys map[string]string
+}
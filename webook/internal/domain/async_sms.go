@@ -0,0 +1,12 @@
+package domain
+
+// AsyncSMS 等着后台发送或者重试的短信
+type AsyncSMS struct {
+	Id      int64
+	TplId   string
+	Args    []string
+	Numbers []string
+	// RetryCnt 已经失败的次数, 第一次发送不算重试, 超过 RetryMax 就放弃
+	RetryCnt int
+	RetryMax int
+}
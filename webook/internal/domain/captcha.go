@@ -0,0 +1,17 @@
+package domain
+
+type CaptchaKind string
+
+const (
+	// CaptchaDigit 图片上是几位数字, 原样输入
+	CaptchaDigit CaptchaKind = "digit"
+	// CaptchaMath 图片上是一道加减法, 输入结果
+	CaptchaMath CaptchaKind = "math"
+)
+
+type Captcha struct {
+	Id   string
+	Kind CaptchaKind
+	// Image PNG 格式的图片
+	Image []byte
+}
@@ -0,0 +1,43 @@
+package domain
+
+const (
+	// RoleAdmin 管理员, 后台所有操作都可以做
+	RoleAdmin = "admin"
+	// RoleOperator 运营, 只能查看用户
+	RoleOperator = "operator"
+)
+
+const (
+	PermUserRead = "user:read"
+	PermUserBan  = "user:ban"
+	// PermSMSRead 查看短信发送记录和统计, 用户反馈收不到验证码的时候排查用
+	PermSMSRead = "sms:read"
+)
+
+var rolePermissions = map[string][]string{
+	RoleAdmin:    {PermUserRead, PermUserBan, PermSMSRead},
+	RoleOperator: {PermUserRead, PermSMSRead},
+}
+
+// HasRole 有其中任意一个角色就返回 true
+func HasRole(roles []string, want ...string) bool {
+	for _, r := range roles {
+		for _, w := range want {
+			if r == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func HasPermission(roles []string, perm string) bool {
+	for _, r := range roles {
+		for _, p := range rolePermissions[r] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
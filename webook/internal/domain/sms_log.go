@@ -0,0 +1,48 @@
+package domain
+
+import "time"
+
+// SMSLog 调用一次服务商发短信的记录, 重试的话每次都有一条
+type SMSLog struct {
+	Provider string
+	TplId    string
+	// BizId 服务商的回执 id, 服务商不支持的时候为空
+	BizId string
+	// Phones 打过码的手机号
+	Phones  []string
+	Success bool
+	Err     string
+	Latency time.Duration
+	Ctime   time.Time
+}
+
+// SMSDelivery 服务商回调的送达状态
+type SMSDelivery struct {
+	Provider  string
+	BizId     string
+	Delivered bool
+	// Err 没送达的原因, 服务商给的错误码和描述
+	Err string
+	// ReportTime 服务商拿到运营商回执的时间
+	ReportTime time.Time
+}
+
+// SMSStat 一个服务商一天的发送情况
+type SMSStat struct {
+	// Day 格式是 2006-01-02
+	Day      string
+	Provider string
+	Total    int64
+	Failed   int64
+	// Delivered 和 Undelivered 是回调了送达状态的, 剩下的还不知道有没有送达
+	Delivered   int64
+	Undelivered int64
+	AvgLatency  time.Duration
+}
+
+func (s SMSStat) FailureRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Total)
+}
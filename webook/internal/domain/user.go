@@ -4,13 +4,49 @@ import "time"
 
 // User 领域对象, DDD中的entity
 type User struct {
-	Id         int64
-	Email      string
-	Phone      string
-	Password   string
-	Nickname   string
-	AboutMe    string
-	Birthday   time.Time
-	Ctime      time.Time
-	WechatInfo WechatInfo
+	Id    int64
+	Email string
+	// EmailVerified 邮箱是否通过验证码验证过
+	EmailVerified bool
+	Phone         string
+	Password      string
+	Nickname      string
+	AboutMe       string
+	Birthday      time.Time
+	Ctime         time.Time
+	WechatInfo    WechatInfo
+	Roles         []string
+	Status        UserStatus
+}
+
+type UserStatus uint8
+
+const (
+	UserStatusActive UserStatus = iota
+	// UserStatusBanned 被封禁, 不能登录
+	UserStatusBanned
+)
+
+// LoginMethod 用户可以用来登录的方式, 一个用户可以绑定多种
+type LoginMethod string
+
+const (
+	LoginMethodEmail  LoginMethod = "email"
+	LoginMethodPhone  LoginMethod = "phone"
+	LoginMethodWechat LoginMethod = "wechat"
+)
+
+// LoginMethods 已经绑定的登录方式, 邮箱可以用密码或者验证码登录
+func (u User) LoginMethods() []LoginMethod {
+	var res []LoginMethod
+	if u.Email != "" {
+		res = append(res, LoginMethodEmail)
+	}
+	if u.Phone != "" {
+		res = append(res, LoginMethodPhone)
+	}
+	if u.WechatInfo.OpenId != "" {
+		res = append(res, LoginMethodWechat)
+	}
+	return res
 }
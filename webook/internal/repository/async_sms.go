@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"encoding/json"
+	"time"
+)
+
+var ErrWaitingSMSNotFound = dao.ErrWaitingSMSNotFound
+var ErrAsyncSMSPreempted = dao.ErrAsyncSMSPreempted
+
+type AsyncSMSRepository interface {
+	// Add nextTime 是第一次重试的时间
+	Add(ctx context.Context, s domain.AsyncSMS, nextTime time.Time) error
+	PreemptWaiting(ctx context.Context, lease time.Duration) (domain.AsyncSMS, error)
+	ReportSuccess(ctx context.Context, id int64) error
+	// ReportFailure final 为 true 就不会再重试了
+	ReportFailure(ctx context.Context, id int64, retryCnt int, nextTime time.Time, final bool) error
+}
+
+type DBAsyncSMSRepository struct {
+	dao dao.AsyncSMSDAO
+}
+
+func NewAsyncSMSRepository(dao dao.AsyncSMSDAO) AsyncSMSRepository {
+	return &DBAsyncSMSRepository{
+		dao: dao,
+	}
+}
+
+func (a *DBAsyncSMSRepository) Add(ctx context.Context, s domain.AsyncSMS, nextTime time.Time) error {
+	args, err := json.Marshal(s.Args)
+	if err != nil {
+		return err
+	}
+	numbers, err := json.Marshal(s.Numbers)
+	if err != nil {
+		return err
+	}
+	return a.dao.Insert(ctx, dao.AsyncSMS{
+		TplId:    s.TplId,
+		Args:     string(args),
+		Numbers:  string(numbers),
+		RetryCnt: s.RetryCnt,
+		RetryMax: s.RetryMax,
+		NextTime: nextTime.UnixMilli(),
+	})
+}
+
+func (a *DBAsyncSMSRepository) PreemptWaiting(ctx context.Context, lease time.Duration) (domain.AsyncSMS, error) {
+	s, err := a.dao.GetWaiting(ctx, lease)
+	if err != nil {
+		return domain.AsyncSMS{}, err
+	}
+	res := domain.AsyncSMS{
+		Id:       s.Id,
+		TplId:    s.TplId,
+		RetryCnt: s.RetryCnt,
+		RetryMax: s.RetryMax,
+	}
+	err = json.Unmarshal([]byte(s.Args), &res.Args)
+	if err != nil {
+		return domain.AsyncSMS{}, err
+	}
+	err = json.Unmarshal([]byte(s.Numbers), &res.Numbers)
+	return res, err
+}
+
+func (a *DBAsyncSMSRepository) ReportSuccess(ctx context.Context, id int64) error {
+	return a.dao.MarkSuccess(ctx, id)
+}
+
+func (a *DBAsyncSMSRepository) ReportFailure(ctx context.Context, id int64, retryCnt int, nextTime time.Time, final bool) error {
+	return a.dao.MarkFailed(ctx, id, retryCnt, nextTime.UnixMilli(), final)
+}
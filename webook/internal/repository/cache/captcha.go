@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+type CaptchaCache interface {
+	SetAnswer(ctx context.Context, id string, answer string) error
+	// TakeAnswer 取出来就删掉, 一道题只能答一次
+	TakeAnswer(ctx context.Context, id string) (string, error)
+	// SetPass 答对之后发的通行 token, 值是答题时候的 IP
+	SetPass(ctx context.Context, token string, ip string) error
+	TakePass(ctx context.Context, token string) (string, error)
+}
+
+type RedisCaptchaCache struct {
+	client           redis.Cmdable
+	answerExpiration time.Duration
+	passExpiration   time.Duration
+}
+
+func NewCaptchaCache(client redis.Cmdable) CaptchaCache {
+	return &RedisCaptchaCache{
+		client:           client,
+		answerExpiration: time.Minute * 5,
+		passExpiration:   time.Minute * 2,
+	}
+}
+
+func (c *RedisCaptchaCache) SetAnswer(ctx context.Context, id string, answer string) error {
+	return c.client.Set(ctx, c.answerKey(id), answer, c.answerExpiration).Err()
+}
+
+func (c *RedisCaptchaCache) TakeAnswer(ctx context.Context, id string) (string, error) {
+	return c.client.GetDel(ctx, c.answerKey(id)).Result()
+}
+
+func (c *RedisCaptchaCache) SetPass(ctx context.Context, token string, ip string) error {
+	return c.client.Set(ctx, c.passKey(token), ip, c.passExpiration).Err()
+}
+
+func (c *RedisCaptchaCache) TakePass(ctx context.Context, token string) (string, error) {
+	return c.client.GetDel(ctx, c.passKey(token)).Result()
+}
+
+func (c *RedisCaptchaCache) answerKey(id string) string {
+	return fmt.Sprintf("captcha:answer:%s", id)
+}
+
+func (c *RedisCaptchaCache) passKey(token string) string {
+	return fmt.Sprintf("captcha:pass:%s", token)
+}
@@ -17,9 +17,15 @@ var ErrCodeVerifyTooMany = errors.New("验证太频繁")
 //go:embed lua/verify_code.lua
 var luaVerifyCode string
 
+//go:embed lua/delete_code.lua
+var luaDeleteCode string
+
+// CodeCache channel 是发送渠道, 比如 phone 和 email, 不同渠道的验证码互不影响
 type CodeCache interface {
-	Set(ctx context.Context, biz, phone, code string) error
-	Verify(ctx context.Context, biz, phone, code string) (bool, error)
+	Set(ctx context.Context, channel, biz, target, code string) error
+	Verify(ctx context.Context, channel, biz, target, code string) (bool, error)
+	// Delete 验证码没有发出去的时候删掉, 不然要等一分钟才能重新发
+	Delete(ctx context.Context, channel, biz, target, code string) error
 }
 
 type RedisCodeCache struct {
@@ -32,8 +38,8 @@ func NewCodeCache(client redis.Cmdable) CodeCache {
 	}
 }
 
-func (c *RedisCodeCache) Set(ctx context.Context, biz, phone, code string) error {
-	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(biz, phone)}, code).Int()
+func (c *RedisCodeCache) Set(ctx context.Context, channel, biz, target, code string) error {
+	res, err := c.client.Eval(ctx, luaSetCode, []string{c.key(channel, biz, target)}, code).Int()
 	if err != nil {
 		return err
 	}
@@ -47,8 +53,8 @@ func (c *RedisCodeCache) Set(ctx context.Context, biz, phone, code string) error
 	}
 }
 
-func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
-	res, err := c.client.Eval(ctx, luaVerifyCode, []string{c.key(biz, phone)}, code).Int()
+func (c *RedisCodeCache) Verify(ctx context.Context, channel, biz, target, code string) (bool, error) {
+	res, err := c.client.Eval(ctx, luaVerifyCode, []string{c.key(channel, biz, target)}, code).Int()
 	if err != nil {
 		return false, err
 	}
@@ -63,6 +69,11 @@ func (c *RedisCodeCache) Verify(ctx context.Context, biz, phone, code string) (b
 	}
 }
 
-func (c *RedisCodeCache) key(biz, phone string) string {
-	return fmt.Sprintf("phone_code:%s:%s", biz, phone)
+func (c *RedisCodeCache) Delete(ctx context.Context, channel, biz, target, code string) error {
+	return c.client.Eval(ctx, luaDeleteCode, []string{c.key(channel, biz, target)}, code).Err()
+}
+
+// key 短信渠道还是 phone_code:biz:phone, 和以前的 key 保持一致
+func (c *RedisCodeCache) key(channel, biz, target string) string {
+	return fmt.Sprintf("%s_code:%s:%s", channel, biz, target)
 }
@@ -0,0 +1,9 @@
+-- 只删自己存的验证码, 免得删掉别人新发的
+local key = KEYS[1]
+local cntKey = key..":cnt"
+
+if redis.call("get", key) == ARGV[1] then
+    redis.call("del", key, cntKey)
+    return 1
+end
+return 0
@@ -14,6 +14,7 @@ var ErrKeyNotExist = redis.Nil
 type UserCache interface {
 	Get(ctx context.Context, id int64) (domain.User, error)
 	Set(ctx context.Context, u domain.User) error
+	Del(ctx context.Context, id int64) error
 	Key(id int64) string
 }
 
@@ -55,6 +56,10 @@ func (cache *RedisCache) Set(ctx context.Context, u domain.User) error {
 	return cache.client.Set(ctx, key, val, cache.expiration).Err()
 }
 
+func (cache *RedisCache) Del(ctx context.Context, id int64) error {
+	return cache.client.Del(ctx, cache.Key(id)).Err()
+}
+
 func (cache *RedisCache) Key(id int64) string {
 	return fmt.Sprintf("user:info:%d", id)
 }
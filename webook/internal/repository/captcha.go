@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"basic_go/webook/internal/repository/cache"
+	"context"
+)
+
+// ErrCaptchaNotFound 题目或者通行 token 不存在, 过期了或者已经用过了
+var ErrCaptchaNotFound = cache.ErrKeyNotExist
+
+type CaptchaRepository interface {
+	SetAnswer(ctx context.Context, id string, answer string) error
+	TakeAnswer(ctx context.Context, id string) (string, error)
+	SetPass(ctx context.Context, token string, ip string) error
+	TakePass(ctx context.Context, token string) (string, error)
+}
+
+type CachedCaptchaRepository struct {
+	cache cache.CaptchaCache
+}
+
+func NewCaptchaRepository(cache cache.CaptchaCache) CaptchaRepository {
+	return &CachedCaptchaRepository{
+		cache: cache,
+	}
+}
+
+func (c *CachedCaptchaRepository) SetAnswer(ctx context.Context, id string, answer string) error {
+	return c.cache.SetAnswer(ctx, id, answer)
+}
+
+func (c *CachedCaptchaRepository) TakeAnswer(ctx context.Context, id string) (string, error) {
+	return c.cache.TakeAnswer(ctx, id)
+}
+
+func (c *CachedCaptchaRepository) SetPass(ctx context.Context, token string, ip string) error {
+	return c.cache.SetPass(ctx, token, ip)
+}
+
+func (c *CachedCaptchaRepository) TakePass(ctx context.Context, token string) (string, error) {
+	return c.cache.TakePass(ctx, token)
+}
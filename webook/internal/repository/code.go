@@ -9,8 +9,9 @@ var ErrCodeVerifyTooMany = cache.ErrCodeVerifyTooMany
 var ErrCodeSendTooMany = cache.ErrCodeSendTooMany
 
 type CodeRepository interface {
-	Set(ctx context.Context, biz, phone, code string) error
-	Verify(ctx context.Context, biz, phone, code string) (bool, error)
+	Set(ctx context.Context, channel, biz, target, code string) error
+	Verify(ctx context.Context, channel, biz, target, code string) (bool, error)
+	Delete(ctx context.Context, channel, biz, target, code string) error
 }
 
 type CachedCodeRepository struct {
@@ -23,10 +24,14 @@ func NewCodeRepository(cache cache.CodeCache) CodeRepository {
 	}
 }
 
-func (c *CachedCodeRepository) Set(ctx context.Context, biz, phone, code string) error {
-	return c.cache.Set(ctx, biz, phone, code)
+func (c *CachedCodeRepository) Set(ctx context.Context, channel, biz, target, code string) error {
+	return c.cache.Set(ctx, channel, biz, target, code)
 }
 
-func (c *CachedCodeRepository) Verify(ctx context.Context, biz, phone, code string) (bool, error) {
-	return c.cache.Verify(ctx, biz, phone, code)
+func (c *CachedCodeRepository) Verify(ctx context.Context, channel, biz, target, code string) (bool, error) {
+	return c.cache.Verify(ctx, channel, biz, target, code)
+}
+
+func (c *CachedCodeRepository) Delete(ctx context.Context, channel, biz, target, code string) error {
+	return c.cache.Delete(ctx, channel, biz, target, code)
 }
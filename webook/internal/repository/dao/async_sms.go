@@ -0,0 +1,110 @@
+package dao
+
+import (
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+var ErrWaitingSMSNotFound = gorm.ErrRecordNotFound
+
+// ErrAsyncSMSPreempted 查出来的那条被别的实例先抢走了
+var ErrAsyncSMSPreempted = errors.New("异步短信已经被抢占")
+
+const (
+	AsyncSMSStatusWaiting uint8 = iota
+	AsyncSMSStatusSuccess
+	// AsyncSMSStatusFailed 重试次数用完了, 不会再发
+	AsyncSMSStatusFailed
+)
+
+type AsyncSMSDAO interface {
+	Insert(ctx context.Context, s AsyncSMS) error
+	// GetWaiting 抢占一条到了重试时间的短信, lease 时间之内别的实例拿不到
+	// 实例在 lease 之内挂了, 过期之后别的实例会重新拿到
+	GetWaiting(ctx context.Context, lease time.Duration) (AsyncSMS, error)
+	MarkSuccess(ctx context.Context, id int64) error
+	MarkFailed(ctx context.Context, id int64, retryCnt int, nextTime int64, final bool) error
+}
+
+type GORMAsyncSMSDAO struct {
+	db *gorm.DB
+}
+
+func NewAsyncSMSDAO(db *gorm.DB) AsyncSMSDAO {
+	return &GORMAsyncSMSDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMAsyncSMSDAO) Insert(ctx context.Context, s AsyncSMS) error {
+	now := time.Now().UnixMilli()
+	s.Ctime = now
+	s.Utime = now
+	s.Status = AsyncSMSStatusWaiting
+	return dao.db.WithContext(ctx).Create(&s).Error
+}
+
+func (dao *GORMAsyncSMSDAO) GetWaiting(ctx context.Context, lease time.Duration) (AsyncSMS, error) {
+	now := time.Now().UnixMilli()
+	var s AsyncSMS
+	err := dao.db.WithContext(ctx).
+		Where("status = ? AND next_time <= ?", AsyncSMSStatusWaiting, now).
+		Order("next_time").First(&s).Error
+	if err != nil {
+		return AsyncSMS{}, err
+	}
+	// next_time 当乐观锁用, 改成功了才算抢到
+	res := dao.db.WithContext(ctx).Model(&AsyncSMS{}).
+		Where("id = ? AND next_time = ?", s.Id, s.NextTime).
+		Updates(map[string]any{
+			"next_time": now + lease.Milliseconds(),
+			"utime":     now,
+		})
+	if res.Error != nil {
+		return AsyncSMS{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return AsyncSMS{}, ErrAsyncSMSPreempted
+	}
+	return s, nil
+}
+
+func (dao *GORMAsyncSMSDAO) MarkSuccess(ctx context.Context, id int64) error {
+	return dao.db.WithContext(ctx).Model(&AsyncSMS{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status": AsyncSMSStatusSuccess,
+			"args":   "",
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
+func (dao *GORMAsyncSMSDAO) MarkFailed(ctx context.Context, id int64, retryCnt int, nextTime int64, final bool) error {
+	updates := map[string]any{
+		"status":    AsyncSMSStatusWaiting,
+		"retry_cnt": retryCnt,
+		"next_time": nextTime,
+		"utime":     time.Now().UnixMilli(),
+	}
+	if final {
+		updates["status"] = AsyncSMSStatusFailed
+		updates["args"] = ""
+	}
+	return dao.db.WithContext(ctx).Model(&AsyncSMS{}).Where("id = ?", id).Updates(updates).Error
+}
+
+type AsyncSMS struct {
+	Id    int64 `gorm:"primaryKey,autoIncrement"`
+	TplId string
+	// Args 和 Numbers 都是 JSON 数组
+	// Args 里面是明文的验证码, 成功或者放弃之后就清空, 不留在库里
+	Args     string
+	Numbers  string
+	RetryCnt int
+	RetryMax int
+	Status   uint8 `gorm:"index:idx_status_next_time"`
+	NextTime int64 `gorm:"index:idx_status_next_time"`
+	Ctime    int64
+	Utime    int64
+}
@@ -0,0 +1,21 @@
+// Package daotest 测试用的数据库
+package daotest
+
+import (
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// NewMemoryDB 每次都是一个新的 sqlite 内存数据库, 还没有建表
+// 不依赖 dao, dao 自己的测试也能用
+func NewMemoryDB(t testing.TB) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	// 内存数据库每个连接都是独立的一个库
+	sqlDB.SetMaxOpenConns(1)
+	return db
+}
@@ -0,0 +1,97 @@
+package dao
+
+import (
+	"context"
+	"gorm.io/gorm"
+)
+
+const (
+	SMSLogStatusSuccess uint8 = iota + 1
+	SMSLogStatusFailed
+)
+
+// 送达状态, 要等服务商回调才知道
+const (
+	SMSLogDeliveryUnknown uint8 = iota
+	SMSLogDeliveryDelivered
+	SMSLogDeliveryUndelivered
+)
+
+type SMSLogDAO interface {
+	Insert(ctx context.Context, l SMSLog) error
+	// UpdateDelivery 一次发给多个号码的话会回调多次, 有一个没送达就算没送达
+	UpdateDelivery(ctx context.Context, provider string, bizId string, delivery uint8, errMsg string, dtime int64) error
+	// Stats 按天和服务商分组, startDay 和 endDay 都包含在内
+	Stats(ctx context.Context, startDay string, endDay string) ([]SMSStat, error)
+}
+
+type GORMSMSLogDAO struct {
+	db *gorm.DB
+}
+
+func NewSMSLogDAO(db *gorm.DB) SMSLogDAO {
+	return &GORMSMSLogDAO{
+		db: db,
+	}
+}
+
+func (dao *GORMSMSLogDAO) Insert(ctx context.Context, l SMSLog) error {
+	return dao.db.WithContext(ctx).Create(&l).Error
+}
+
+func (dao *GORMSMSLogDAO) UpdateDelivery(ctx context.Context, provider string, bizId string,
+	delivery uint8, errMsg string, dtime int64) error {
+	return dao.db.WithContext(ctx).Model(&SMSLog{}).
+		Where("provider = ? AND biz_id = ? AND delivery <> ?", provider, bizId, SMSLogDeliveryUndelivered).
+		Updates(map[string]any{
+			"delivery":      delivery,
+			"delivery_err":  errMsg,
+			"delivery_time": dtime,
+		}).Error
+}
+
+func (dao *GORMSMSLogDAO) Stats(ctx context.Context, startDay string, endDay string) ([]SMSStat, error) {
+	var res []SMSStat
+	err := dao.db.WithContext(ctx).Model(&SMSLog{}).
+		Select("day, provider, COUNT(*) AS total, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS failed, "+
+			"SUM(CASE WHEN delivery = ? THEN 1 ELSE 0 END) AS delivered, "+
+			"SUM(CASE WHEN delivery = ? THEN 1 ELSE 0 END) AS undelivered, "+
+			"AVG(latency) AS avg_latency", SMSLogStatusFailed, SMSLogDeliveryDelivered, SMSLogDeliveryUndelivered).
+		Where("day BETWEEN ? AND ?", startDay, endDay).
+		Group("day, provider").
+		Order("day, provider").
+		Scan(&res).Error
+	return res, err
+}
+
+type SMSLog struct {
+	Id       int64  `gorm:"primaryKey,autoIncrement"`
+	Provider string `gorm:"type:varchar(32);index:idx_day_provider;index:idx_provider_biz_id"`
+	TplId    string `gorm:"type:varchar(64)"`
+	// BizId 服务商的回执 id, 回调送达状态的时候用
+	BizId string `gorm:"type:varchar(64);index:idx_provider_biz_id"`
+	// Phones 打过码的手机号, 逗号分隔
+	Phones string `gorm:"type:varchar(1024)"`
+	Status uint8
+	Err    string `gorm:"type:varchar(1024)"`
+	// Latency 毫秒
+	Latency int64
+	// Day 发送的日期, 2006-01-02, 按天统计用
+	Day   string `gorm:"type:varchar(10);index:idx_day_provider"`
+	Ctime int64
+	// Delivery 送达状态, DeliveryTime 是服务商拿到回执的时间, 毫秒
+	Delivery     uint8
+	DeliveryErr  string `gorm:"type:varchar(256)"`
+	DeliveryTime int64
+}
+
+type SMSStat struct {
+	Day         string
+	Provider    string
+	Total       int64
+	Failed      int64
+	Delivered   int64
+	Undelivered int64
+	AvgLatency  float64
+}
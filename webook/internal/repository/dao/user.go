@@ -6,6 +6,7 @@ import (
 	"errors"
 	"github.com/go-sql-driver/mysql"
 	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
@@ -17,7 +18,13 @@ type UserDAO interface {
 	FindByEmail(ctx context.Context, email string) (User, error)
 	FindByPhone(ctx context.Context, phone string) (User, error)
 	FindById(ctx context.Context, id int64) (User, error)
+	// FindPasswordById 只查密码的 hash
+	FindPasswordById(ctx context.Context, id int64) (string, error)
 	FindByWechat(ctx context.Context, openId string) (User, error)
+	UpdateById(ctx context.Context, u User) error
+	Search(ctx context.Context, keyword string, offset int, limit int) ([]User, int64, error)
+	UpdateStatus(ctx context.Context, id int64, status uint8) error
+	UpdateColumns(ctx context.Context, id int64, columns map[string]any) error
 }
 
 type GORMUserDAO struct {
@@ -35,6 +42,11 @@ func (dao *GORMUserDAO) Insert(ctx context.Context, u User) error {
 	u.Utime = now
 	u.Ctime = now
 	err := dao.db.WithContext(ctx).Create(&u).Error
+	return dao.convertErr(err)
+}
+
+// convertErr 邮箱、手机号、微信 openid 都是唯一索引, 冲突统一返回 ErrUserDuplicate
+func (dao *GORMUserDAO) convertErr(err error) error {
 	var mysqlErr *mysql.MySQLError
 	if errors.As(err, &mysqlErr) {
 		const uniqueConflictsErrNo uint16 = 1062
@@ -63,17 +75,75 @@ func (dao *GORMUserDAO) FindById(ctx context.Context, id int64) (User, error) {
 	return u, err
 }
 
+func (dao *GORMUserDAO) FindPasswordById(ctx context.Context, id int64) (string, error) {
+	var u User
+	err := dao.db.WithContext(ctx).Select("password").Where("id = ?", id).First(&u).Error
+	return u.Password, err
+}
+
 func (dao *GORMUserDAO) FindByWechat(ctx context.Context, openId string) (User, error) {
 	var u User
 	err := dao.db.WithContext(ctx).Where("wechat_open_id = ?", openId).First(&u).Error
 	return u, err
 }
 
+// UpdateById 只更新非零值字段, 没传的字段保持原样
+func (dao *GORMUserDAO) UpdateById(ctx context.Context, u User) error {
+	u.Utime = time.Now().UnixMilli()
+	err := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", u.Id).Updates(&u).Error
+	return dao.convertErr(err)
+}
+
+// UpdateColumns 要把字段更新成零值或者 NULL 的时候用, 比如解绑
+func (dao *GORMUserDAO) UpdateColumns(ctx context.Context, id int64, columns map[string]any) error {
+	updates := make(map[string]any, len(columns)+1)
+	for k, v := range columns {
+		updates[k] = v
+	}
+	updates["utime"] = time.Now().UnixMilli()
+	err := dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).Updates(updates).Error
+	return dao.convertErr(err)
+}
+
+// likeEscape 转义字符也当参数传, MySQL 和 SQLite 对字符串里的反斜杠处理不一样
+const likeEscape = `\`
+
+// likeEscaper keyword 里的 % 和 _ 按字面匹配, 不当通配符
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search 按照邮箱、手机号、昵称的前缀搜索, keyword 为空就是全部
+func (dao *GORMUserDAO) Search(ctx context.Context, keyword string, offset int, limit int) ([]User, int64, error) {
+	query := dao.db.WithContext(ctx).Model(&User{})
+	if keyword != "" {
+		prefix := likeEscaper.Replace(keyword) + "%"
+		query = query.Where("email LIKE ? ESCAPE ? OR phone LIKE ? ESCAPE ? OR nickname LIKE ? ESCAPE ?",
+			prefix, likeEscape, prefix, likeEscape, prefix, likeEscape)
+	}
+	var total int64
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	var res []User
+	err = query.Order("id DESC").Offset(offset).Limit(limit).Find(&res).Error
+	return res, total, err
+}
+
+// UpdateStatus 状态可能是零值, 所以用 map 更新
+func (dao *GORMUserDAO) UpdateStatus(ctx context.Context, id int64, status uint8) error {
+	return dao.db.WithContext(ctx).Model(&User{}).Where("id = ?", id).
+		Updates(map[string]any{
+			"status": status,
+			"utime":  time.Now().UnixMilli(),
+		}).Error
+}
+
 // User 直接对应数据库表, entity 或 model
 type User struct {
 	Id            int64          `gorm:"primaryKey,autoIncrement"`
 	Email         sql.NullString `gorm:"unique"`
-	Phone         sql.NullString
+	EmailVerified bool
+	Phone         sql.NullString `gorm:"unique"`
 	Password      string
 	Nickname      string `gorm:"size:36"`
 	Birthday      int64
@@ -82,4 +152,8 @@ type User struct {
 	Utime         int64
 	WechatUnionId sql.NullString
 	WechatOpenId  sql.NullString `gorm:"unique"`
+	// Roles 逗号分隔的角色, 比如 admin,operator
+	Roles string `gorm:"size:255"`
+	// Status 0 正常, 1 封禁
+	Status uint8
 }
@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGORMUserDAO_Search(t *testing.T) {
+	db := daotest.NewMemoryDB(t)
+	require.NoError(t, InitTable(db))
+
+	dao := NewUserDAO(db)
+	ctx := context.Background()
+	for _, nickname := range []string{"100%_off", "100abc", "a_b", "axb", `c\d`, "cxd"} {
+		require.NoError(t, db.Create(&User{Nickname: nickname}).Error)
+	}
+
+	testCases := []struct {
+		name      string
+		keyword   string
+		wantNames []string
+	}{
+		{name: "普通前缀", keyword: "100", wantNames: []string{"100abc", "100%_off"}},
+		{name: "百分号按字面匹配", keyword: "100%", wantNames: []string{"100%_off"}},
+		{name: "下划线按字面匹配", keyword: "a_", wantNames: []string{"a_b"}},
+		{name: "反斜杠按字面匹配", keyword: `c\`, wantNames: []string{`c\d`}},
+		{name: "只有百分号", keyword: "%", wantNames: []string{}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			users, total, err := dao.Search(ctx, tc.keyword, 0, 10)
+			require.NoError(t, err)
+			names := make([]string, 0, len(users))
+			for _, u := range users {
+				names = append(names, u.Nickname)
+			}
+			assert.Equal(t, tc.wantNames, names)
+			assert.Equal(t, int64(len(tc.wantNames)), total)
+		})
+	}
+}
+
+func TestGORMUserDAO_UpdateColumns(t *testing.T) {
+	db := daotest.NewMemoryDB(t)
+	require.NoError(t, InitTable(db))
+
+	dao := NewUserDAO(db)
+	ctx := context.Background()
+	require.NoError(t, db.Create(&User{Id: 1, Password: "hash"}).Error)
+	// 清掉密码要更新成空字符串, 用 UpdateById 会被当成零值跳过
+	require.NoError(t, dao.UpdateColumns(ctx, 1, map[string]any{"password": ""}))
+	password, err := dao.FindPasswordById(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, password)
+}
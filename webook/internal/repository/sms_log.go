@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository/dao"
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	dayLayout = "2006-01-02"
+	// smsLogErrMax 服务商返回的错误信息可能很长, 超过的部分截掉
+	smsLogErrMax = 1024
+	// smsDeliveryErrMax 送达失败的原因一般就是错误码加一句描述
+	smsDeliveryErrMax = 256
+)
+
+type SMSLogRepository interface {
+	Add(ctx context.Context, l domain.SMSLog) error
+	UpdateDelivery(ctx context.Context, d domain.SMSDelivery) error
+	// Stats start 和 end 所在的那天都包含在内
+	Stats(ctx context.Context, start time.Time, end time.Time) ([]domain.SMSStat, error)
+}
+
+type DBSMSLogRepository struct {
+	dao dao.SMSLogDAO
+}
+
+func NewSMSLogRepository(dao dao.SMSLogDAO) SMSLogRepository {
+	return &DBSMSLogRepository{
+		dao: dao,
+	}
+}
+
+func (r *DBSMSLogRepository) Add(ctx context.Context, l domain.SMSLog) error {
+	status := dao.SMSLogStatusSuccess
+	if !l.Success {
+		status = dao.SMSLogStatusFailed
+	}
+	return r.dao.Insert(ctx, dao.SMSLog{
+		Provider: l.Provider,
+		TplId:    l.TplId,
+		BizId:    l.BizId,
+		Phones:   strings.Join(l.Phones, ","),
+		Status:   status,
+		Err:      truncate(l.Err, smsLogErrMax),
+		Latency:  l.Latency.Milliseconds(),
+		Day:      l.Ctime.Format(dayLayout),
+		Ctime:    l.Ctime.UnixMilli(),
+	})
+}
+
+func (r *DBSMSLogRepository) UpdateDelivery(ctx context.Context, d domain.SMSDelivery) error {
+	delivery := dao.SMSLogDeliveryDelivered
+	if !d.Delivered {
+		delivery = dao.SMSLogDeliveryUndelivered
+	}
+	return r.dao.UpdateDelivery(ctx, d.Provider, d.BizId, delivery,
+		truncate(d.Err, smsDeliveryErrMax), d.ReportTime.UnixMilli())
+}
+
+func (r *DBSMSLogRepository) Stats(ctx context.Context, start time.Time, end time.Time) ([]domain.SMSStat, error) {
+	stats, err := r.dao.Stats(ctx, start.Format(dayLayout), end.Format(dayLayout))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]domain.SMSStat, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, domain.SMSStat{
+			Day:         s.Day,
+			Provider:    s.Provider,
+			Total:       s.Total,
+			Failed:      s.Failed,
+			Delivered:   s.Delivered,
+			Undelivered: s.Undelivered,
+			AvgLatency:  time.Duration(s.AvgLatency * float64(time.Millisecond)),
+		})
+	}
+	return res, nil
+}
+
+// truncate 超过 max 字节的截掉, 不留下半个字符
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
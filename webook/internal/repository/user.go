@@ -7,20 +7,29 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 )
 
 var ErrUserDuplicateEmail = dao.ErrUserDuplicate
+
+// ErrUserDuplicate 邮箱、手机号、微信任意一个唯一索引冲突
+var ErrUserDuplicate = dao.ErrUserDuplicate
 var ErrUserNotFound = dao.ErrUserNotFount
 
 type UserRepository interface {
 	FindById(ctx context.Context, id int64) (domain.User, error)
+	// FindPasswordById 密码的 hash 不放进 domain.User, 也就不会进缓存, 要校验密码的时候单独查
+	FindPasswordById(ctx context.Context, id int64) (string, error)
 	Create(ctx context.Context, u domain.User) error
 	FindByEmail(ctx context.Context, email string) (domain.User, error)
 	FindByPhone(ctx context.Context, phone string) (domain.User, error)
 	FindByWechat(ctx context.Context, openId string) (domain.User, error)
-	entityToDomain(u dao.User) domain.User
-	domainToEntity(u domain.User) dao.User
+	UpdateNonZeroFields(ctx context.Context, u domain.User) error
+	Search(ctx context.Context, keyword string, offset int, limit int) ([]domain.User, int64, error)
+	UpdateStatus(ctx context.Context, id int64, status domain.UserStatus) error
+	UpdatePassword(ctx context.Context, id int64, hash string) error
+	ClearLoginMethod(ctx context.Context, id int64, method domain.LoginMethod) error
 }
 
 type CachedUserRepository struct {
@@ -57,6 +66,10 @@ func (r *CachedUserRepository) FindById(ctx context.Context, id int64) (domain.U
 	//return domain.User{}, cache.ErrKeyNotExist
 }
 
+func (r *CachedUserRepository) FindPasswordById(ctx context.Context, id int64) (string, error) {
+	return r.dao.FindPasswordById(ctx, id)
+}
+
 func (r *CachedUserRepository) Create(ctx context.Context, u domain.User) error {
 	return r.dao.Insert(ctx, r.domainToEntity(u))
 }
@@ -86,19 +99,76 @@ func (r *CachedUserRepository) FindByWechat(ctx context.Context, openId string)
 	return r.entityToDomain(u), nil
 }
 
+// UpdateNonZeroFields 更新完数据库之后直接删缓存, 下一次 FindById 会重新加载
+func (r *CachedUserRepository) UpdateNonZeroFields(ctx context.Context, u domain.User) error {
+	err := r.dao.UpdateById(ctx, r.domainToEntity(u))
+	if err != nil {
+		return err
+	}
+	return r.cache.Del(ctx, u.Id)
+}
+
+func (r *CachedUserRepository) Search(ctx context.Context, keyword string, offset int, limit int) ([]domain.User, int64, error) {
+	us, total, err := r.dao.Search(ctx, keyword, offset, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	res := make([]domain.User, 0, len(us))
+	for _, u := range us {
+		res = append(res, r.entityToDomain(u))
+	}
+	return res, total, nil
+}
+
+func (r *CachedUserRepository) UpdateStatus(ctx context.Context, id int64, status domain.UserStatus) error {
+	err := r.dao.UpdateStatus(ctx, id, uint8(status))
+	if err != nil {
+		return err
+	}
+	return r.cache.Del(ctx, id)
+}
+
+// UpdatePassword hash 是已经加密过的密码, 为空就是清掉密码, 密码不进缓存, 所以不用删缓存
+func (r *CachedUserRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	return r.dao.UpdateColumns(ctx, id, map[string]any{"password": hash})
+}
+
+// ClearLoginMethod 解绑, 对应的字段置成 NULL, 这样唯一索引不会冲突
+func (r *CachedUserRepository) ClearLoginMethod(ctx context.Context, id int64, method domain.LoginMethod) error {
+	var columns map[string]any
+	switch method {
+	case domain.LoginMethodEmail:
+		columns = map[string]any{"email": nil, "email_verified": false}
+	case domain.LoginMethodPhone:
+		columns = map[string]any{"phone": nil}
+	case domain.LoginMethodWechat:
+		columns = map[string]any{"wechat_open_id": nil, "wechat_union_id": nil}
+	default:
+		return fmt.Errorf("未知的登录方式 %s", method)
+	}
+	err := r.dao.UpdateColumns(ctx, id, columns)
+	if err != nil {
+		return err
+	}
+	return r.cache.Del(ctx, id)
+}
+
 func (r *CachedUserRepository) entityToDomain(u dao.User) domain.User {
 	return domain.User{
-		Id:       u.Id,
-		Email:    u.Email.String,
-		Phone:    u.Phone.String,
-		Nickname: u.Nickname,
-		AboutMe:  u.AboutMe,
+		Id:            u.Id,
+		Email:         u.Email.String,
+		EmailVerified: u.EmailVerified,
+		Phone:         u.Phone.String,
+		Nickname:      u.Nickname,
+		AboutMe:       u.AboutMe,
 		WechatInfo: domain.WechatInfo{
 			UnionId: u.WechatUnionId.String,
 			OpenId:  u.WechatOpenId.String,
 		},
-		Birthday: time.UnixMilli(u.Birthday),
+		Birthday: r.toTime(u.Birthday),
 		Ctime:    time.UnixMilli(u.Ctime),
+		Roles:    r.splitRoles(u.Roles),
+		Status:   domain.UserStatus(u.Status),
 	}
 }
 
@@ -109,6 +179,7 @@ func (r *CachedUserRepository) domainToEntity(u domain.User) dao.User {
 			String: u.Email,
 			Valid:  u.Email != "",
 		},
+		EmailVerified: u.EmailVerified,
 		Phone: sql.NullString{
 			String: u.Phone,
 			Valid:  u.Phone != "",
@@ -124,7 +195,32 @@ func (r *CachedUserRepository) domainToEntity(u domain.User) dao.User {
 		Nickname: u.Nickname,
 		AboutMe:  u.AboutMe,
 		Password: u.Password,
-		Ctime:    u.Ctime.UnixMilli(),
+		Birthday: r.toMilli(u.Birthday),
+		Ctime:    r.toMilli(u.Ctime),
 		Utime:    time.Now().UnixMilli(),
+		Roles:    strings.Join(u.Roles, ","),
+		Status:   uint8(u.Status),
+	}
+}
+
+func (r *CachedUserRepository) splitRoles(roles string) []string {
+	if roles == "" {
+		return nil
+	}
+	return strings.Split(roles, ",")
+}
+
+// toMilli 零值时间存成 0, 否则 UpdateNonZeroFields 会把它当成有效值写进去
+func (r *CachedUserRepository) toMilli(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixMilli()
+}
+
+func (r *CachedUserRepository) toTime(milli int64) time.Time {
+	if milli == 0 {
+		return time.Time{}
 	}
+	return time.UnixMilli(milli)
 }
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/pkg/captcha"
+	"basic_go/webook/pkg/clientip"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var ErrCaptchaRequired = errors.New("需要图形验证码")
+var ErrCaptchaInvalid = errors.New("图形验证码不对")
+
+type CaptchaService interface {
+	Generate(ctx context.Context, kind domain.CaptchaKind) (domain.Captcha, error)
+	// Verify 答对了返回通行 token, 答错了返回 ErrCaptchaInvalid, 一道题只能答一次
+	Verify(ctx context.Context, id string, answer string) (string, error)
+	// ConsumePass 通行 token 只能用一次, 而且要和答题的时候是同一个 IP
+	ConsumePass(ctx context.Context, token string) (bool, error)
+}
+
+type captchaService struct {
+	repo repository.CaptchaRepository
+}
+
+func NewCaptchaService(repo repository.CaptchaRepository) CaptchaService {
+	return &captchaService{
+		repo: repo,
+	}
+}
+
+func (svc *captchaService) Generate(ctx context.Context, kind domain.CaptchaKind) (domain.Captcha, error) {
+	var question, answer string
+	switch kind {
+	case domain.CaptchaDigit:
+		question = captcha.RandomDigits(4)
+		answer = question
+	case domain.CaptchaMath:
+		question, answer = captcha.RandomMath()
+	default:
+		return domain.Captcha{}, fmt.Errorf("未知的图形验证码类型 %s", kind)
+	}
+	img, err := captcha.Render(question)
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	id := uuid.New().String()
+	err = svc.repo.SetAnswer(ctx, id, answer)
+	if err != nil {
+		return domain.Captcha{}, err
+	}
+	return domain.Captcha{
+		Id:    id,
+		Kind:  kind,
+		Image: img,
+	}, nil
+}
+
+func (svc *captchaService) Verify(ctx context.Context, id string, answer string) (string, error) {
+	want, err := svc.repo.TakeAnswer(ctx, id)
+	if errors.Is(err, repository.ErrCaptchaNotFound) {
+		return "", ErrCaptchaInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+	if want != answer {
+		return "", ErrCaptchaInvalid
+	}
+	token := uuid.New().String()
+	err = svc.repo.SetPass(ctx, token, clientip.FromContext(ctx))
+	return token, err
+}
+
+func (svc *captchaService) ConsumePass(ctx context.Context, token string) (bool, error) {
+	ip, err := svc.repo.TakePass(ctx, token)
+	if errors.Is(err, repository.ErrCaptchaNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return ip == clientip.FromContext(ctx), nil
+}
+
+type captchaTokenKey struct{}
+
+// WithCaptchaToken 把前端带过来的通行 token 放进 context, CodeService 发送的时候检查
+func WithCaptchaToken(ctx context.Context, token string) context.Context {
+	return context.WithValue(ctx, captchaTokenKey{}, token)
+}
+
+func captchaTokenFromContext(ctx context.Context) string {
+	token, _ := ctx.Value(captchaTokenKey{}).(string)
+	return token
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	svcmocks "basic_go/webook/internal/service/mocks"
+	"basic_go/webook/pkg/clientip"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+)
+
+func newTestCaptchaService(t *testing.T) (CaptchaService, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	return NewCaptchaService(repository.NewCaptchaRepository(cache.NewCaptchaCache(rdb))), mr
+}
+
+// passCaptcha 生成一道题并答对, 返回通行 token
+func passCaptcha(t *testing.T, svc CaptchaService, mr *miniredis.Miniredis, ip string) string {
+	c, err := svc.Generate(context.Background(), domain.CaptchaMath)
+	require.NoError(t, err)
+	answer, err := mr.Get("captcha:answer:" + c.Id)
+	require.NoError(t, err)
+	token, err := svc.Verify(clientip.WithIP(context.Background(), ip), c.Id, answer)
+	require.NoError(t, err)
+	return token
+}
+
+func TestCaptchaService(t *testing.T) {
+	svc, mr := newTestCaptchaService(t)
+	ctx := clientip.WithIP(context.Background(), "1.1.1.1")
+
+	c, err := svc.Generate(ctx, domain.CaptchaDigit)
+	require.NoError(t, err)
+	assert.NotEmpty(t, c.Image)
+	// 答错了题目就作废, 不能接着猜
+	_, err = svc.Verify(ctx, c.Id, "wrong")
+	assert.Equal(t, ErrCaptchaInvalid, err)
+	assert.False(t, mr.Exists("captcha:answer:"+c.Id))
+
+	_, err = svc.Generate(ctx, domain.CaptchaKind("unknown"))
+	assert.Error(t, err)
+
+	token := passCaptcha(t, svc, mr, "1.1.1.1")
+	// 换了 IP 不能用, 而且 token 已经被消耗掉了
+	ok, err := svc.ConsumePass(clientip.WithIP(context.Background(), "2.2.2.2"), token)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = svc.ConsumePass(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	token = passCaptcha(t, svc, mr, "1.1.1.1")
+	ok, err = svc.ConsumePass(ctx, token)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	// 只能用一次
+	ok, err = svc.ConsumePass(ctx, token)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+type fakeLimiter struct {
+	limited bool
+}
+
+func (l *fakeLimiter) Limit(ctx context.Context, key string) (limiter.Result, error) {
+	return limiter.Result{Limited: l.limited}, nil
+}
+
+func TestCaptchaCodeService_Send(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limited bool
+		// token 为 pass 的时候用真的答对之后拿到的 token
+		token   string
+		wantErr error
+		wantCnt int
+	}{
+		{
+			name:    "没到阈值, 不需要图形验证码",
+			wantCnt: 1,
+		},
+		{
+			name:    "到了阈值, 没带 token",
+			limited: true,
+			wantErr: ErrCaptchaRequired,
+		},
+		{
+			name:    "到了阈值, token 不对",
+			limited: true,
+			token:   "abc",
+			wantErr: ErrCaptchaInvalid,
+		},
+		{
+			name:    "到了阈值, 带了答对的 token",
+			limited: true,
+			token:   "pass",
+			wantCnt: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			codeSvc := svcmocks.NewMockCodeService(ctrl)
+			codeSvc.EXPECT().Send(gomock.Any(), "login", "13800000000").Return(nil).Times(tc.wantCnt)
+			captchaSvc, mr := newTestCaptchaService(t)
+			token := tc.token
+			if token == "pass" {
+				token = passCaptcha(t, captchaSvc, mr, "1.1.1.1")
+			}
+			svc := NewCaptchaCodeService(codeSvc, captchaSvc, &fakeLimiter{limited: tc.limited})
+			ctx := WithCaptchaToken(clientip.WithIP(context.Background(), "1.1.1.1"), token)
+			err := svc.Send(ctx, "login", "13800000000")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
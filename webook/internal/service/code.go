@@ -2,42 +2,55 @@ package service
 
 import (
 	"basic_go/webook/internal/repository"
-	"basic_go/webook/internal/service/sms"
+	"basic_go/webook/internal/service/sms/ratelimit"
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"math/rand"
 )
 
+var (
+	ErrCodeSendPhoneLimited = ratelimit.ErrPhoneLimited
+	ErrCodeSendIPLimited    = ratelimit.ErrIPLimited
+)
+
+// CodeService target 是手机号还是邮箱取决于用的哪个 CodeSender
 type CodeService interface {
-	Send(ctx context.Context, biz string, phone string) error
-	Verify(ctx context.Context, biz string, phone string, inputCode string) (bool, error)
+	Send(ctx context.Context, biz string, target string) error
+	Verify(ctx context.Context, biz string, target string, inputCode string) (bool, error)
 }
 
 type codeService struct {
-	repo repository.CodeRepository
-	sms  sms.Service
+	repo   repository.CodeRepository
+	sender CodeSender
 }
 
-func NewCodeService(repo repository.CodeRepository, smsSvc sms.Service) CodeService {
+func NewCodeService(repo repository.CodeRepository, sender CodeSender) CodeService {
 	return &codeService{
-		repo: repo,
-		sms:  smsSvc,
+		repo:   repo,
+		sender: sender,
 	}
 }
 
-func (svc *codeService) Send(ctx context.Context, biz string, phone string) error {
+func (svc *codeService) Send(ctx context.Context, biz string, target string) error {
 	code := svc.generate()
-	err := svc.repo.Set(ctx, biz, phone, code)
+	err := svc.repo.Set(ctx, svc.sender.Channel(), biz, target, code)
 	if err != nil {
 		return err
 	}
-	const codeTplId = "1877556"
-	return svc.sms.Send(ctx, codeTplId, []string{code}, phone)
+	err = svc.sender.SendCode(ctx, target, code)
+	// 被限流了验证码根本没发出去, 删掉存的验证码, 不然还会占着一分钟的重发间隔
+	if errors.Is(err, ErrCodeSendPhoneLimited) || errors.Is(err, ErrCodeSendIPLimited) {
+		if er := svc.repo.Delete(ctx, svc.sender.Channel(), biz, target, code); er != nil {
+			log.Println("删除没有发出去的验证码失败", er)
+		}
+	}
+	return err
 }
 
-func (svc *codeService) Verify(ctx context.Context, biz string, phone string, inputCode string) (bool, error) {
-	ok, err := svc.repo.Verify(ctx, biz, phone, inputCode)
+func (svc *codeService) Verify(ctx context.Context, biz string, target string, inputCode string) (bool, error) {
+	ok, err := svc.repo.Verify(ctx, svc.sender.Channel(), biz, target, inputCode)
 	if errors.Is(err, repository.ErrCodeVerifyTooMany) {
 		return false, nil
 	}
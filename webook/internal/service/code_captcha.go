@@ -0,0 +1,60 @@
+package service
+
+import (
+	"basic_go/webook/pkg/clientip"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"fmt"
+)
+
+// captchaCodeService 同一个 IP 发送验证码超过阈值之后, 要先通过图形验证码才能继续发
+type captchaCodeService struct {
+	svc        CodeService
+	captchaSvc CaptchaService
+	// limiter 触发限流就说明这个 IP 超过阈值了
+	limiter limiter.Limiter
+}
+
+func NewCaptchaCodeService(svc CodeService, captchaSvc CaptchaService, l limiter.Limiter) CodeService {
+	return &captchaCodeService{
+		svc:        svc,
+		captchaSvc: captchaSvc,
+		limiter:    l,
+	}
+}
+
+func (c *captchaCodeService) Send(ctx context.Context, biz string, target string) error {
+	ip := clientip.FromContext(ctx)
+	if ip != "" {
+		res, err := c.limiter.Limit(ctx, fmt.Sprintf("captcha-gate:ip:%s", ip))
+		if err != nil {
+			return err
+		}
+		if res.Limited {
+			err = c.checkPass(ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return c.svc.Send(ctx, biz, target)
+}
+
+func (c *captchaCodeService) checkPass(ctx context.Context) error {
+	token := captchaTokenFromContext(ctx)
+	if token == "" {
+		return ErrCaptchaRequired
+	}
+	ok, err := c.captchaSvc.ConsumePass(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
+
+func (c *captchaCodeService) Verify(ctx context.Context, biz string, target string, inputCode string) (bool, error) {
+	return c.svc.Verify(ctx, biz, target, inputCode)
+}
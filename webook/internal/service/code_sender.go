@@ -0,0 +1,52 @@
+package service
+
+import (
+	"basic_go/webook/internal/service/email"
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"fmt"
+)
+
+// CodeSender 验证码的发送渠道
+type CodeSender interface {
+	// Channel 渠道的名字, 验证码按照渠道分开存
+	Channel() string
+	SendCode(ctx context.Context, target string, code string) error
+}
+
+type smsCodeSender struct {
+	svc sms.Service
+}
+
+func NewSMSCodeSender(svc sms.Service) CodeSender {
+	return &smsCodeSender{
+		svc: svc,
+	}
+}
+
+func (s *smsCodeSender) Channel() string {
+	return "phone"
+}
+
+func (s *smsCodeSender) SendCode(ctx context.Context, phone string, code string) error {
+	return s.svc.Send(ctx, sms.TplVerifyCode, []string{code}, phone)
+}
+
+type emailCodeSender struct {
+	svc email.Service
+}
+
+func NewEmailCodeSender(svc email.Service) CodeSender {
+	return &emailCodeSender{
+		svc: svc,
+	}
+}
+
+func (s *emailCodeSender) Channel() string {
+	return "email"
+}
+
+func (s *emailCodeSender) SendCode(ctx context.Context, addr string, code string) error {
+	return s.svc.Send(ctx, addr, "webook 验证码",
+		fmt.Sprintf("你的验证码是 %s, 10 分钟内有效, 请不要告诉别人。", code))
+}
@@ -0,0 +1,19 @@
+package localemail
+
+import (
+	"context"
+	"log"
+)
+
+// Service 本地开发用, 邮件内容直接打到日志里
+type Service struct {
+}
+
+func NewService() *Service {
+	return &Service{}
+}
+
+func (s *Service) Send(ctx context.Context, to string, subject string, body string) error {
+	log.Println("邮件: ", to, subject, body)
+	return nil
+}
@@ -0,0 +1,117 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	gosmtp "net/smtp"
+	"strings"
+	"time"
+)
+
+// defaultTimeout 调用方没有给 deadline 的时候, 一封邮件从连接到发完最多这么久
+const defaultTimeout = time.Second * 10
+
+type Service struct {
+	// addr host:port
+	addr    string
+	host    string
+	from    string
+	auth    gosmtp.Auth
+	timeout time.Duration
+}
+
+// NewService username 为空就不做认证, 服务端支持 STARTTLS 的时候会自动升级
+func NewService(addr string, username string, password string, from string) (*Service, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &Service{
+		addr:    addr,
+		host:    host,
+		from:    from,
+		timeout: defaultTimeout,
+	}
+	if username != "" {
+		s.auth = gosmtp.PlainAuth("", username, password, host)
+	}
+	return s, nil
+}
+
+// Timeout 整个 SMTP 会话的超时时间, 调用方的 deadline 更早的话以调用方的为准
+func (s *Service) Timeout(d time.Duration) *Service {
+	s.timeout = d
+	return s
+}
+
+func (s *Service) Send(ctx context.Context, to string, subject string, body string) error {
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	// net/smtp 不认 ctx, 只能靠连接的 deadline, 调用方中途取消的时候也要马上断开
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Now())
+	})
+	defer stop()
+	c, err := gosmtp.NewClient(conn, s.host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer c.Close()
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		err = c.StartTLS(&tls.Config{ServerName: s.host})
+		if err != nil {
+			return err
+		}
+	}
+	if s.auth != nil {
+		err = c.Auth(s.auth)
+		if err != nil {
+			return err
+		}
+	}
+	err = c.Mail(s.from)
+	if err != nil {
+		return err
+	}
+	err = c.Rcpt(to)
+	if err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(s.message(to, subject, body))
+	if err != nil {
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func (s *Service) message(to string, subject string, body string) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "From: %s\r\n", s.from)
+	fmt.Fprintf(&sb, "To: %s\r\n", to)
+	// 标题里有中文, 要按 RFC 2047 编码
+	fmt.Fprintf(&sb, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("\r\n")
+	sb.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(sb.String())
+}
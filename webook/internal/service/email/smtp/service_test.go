@@ -0,0 +1,152 @@
+package smtp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"mime"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeServer 只实现发一封邮件需要的几个命令
+type fakeServer struct {
+	ln   net.Listener
+	auth string
+	from string
+	rcpt string
+	data string
+	done chan struct{}
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	s := &fakeServer{ln: ln, done: make(chan struct{})}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	return s
+}
+
+func (s *fakeServer) serve() {
+	defer close(s.done)
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(cmd) {
+		case "EHLO":
+			_ = tp.PrintfLine("250-localhost")
+			_ = tp.PrintfLine("250 AUTH PLAIN")
+		case "AUTH":
+			s.auth = arg
+			_ = tp.PrintfLine("235 ok")
+		case "MAIL":
+			s.from = arg
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.rcpt = arg
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			lines, err := tp.ReadDotLines()
+			if err != nil {
+				return
+			}
+			s.data = strings.Join(lines, "\n")
+			_ = tp.PrintfLine("250 ok")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func TestService_Send(t *testing.T) {
+	server := newFakeServer(t)
+	svc, err := NewService(server.ln.Addr().String(), "user", "pwd", "noreply@webook.com")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	err = svc.Send(ctx, "123@qq.com", "webook 验证码", "你的验证码是 123456")
+	require.NoError(t, err)
+	<-server.done
+
+	auth, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(server.auth, "PLAIN "))
+	require.NoError(t, err)
+	assert.Equal(t, "\x00user\x00pwd", string(auth))
+	assert.Equal(t, "FROM:<noreply@webook.com>", server.from)
+	assert.Equal(t, "TO:<123@qq.com>", server.rcpt)
+
+	header, body, ok := strings.Cut(server.data, "\n\n")
+	require.True(t, ok)
+	assert.Equal(t, "你的验证码是 123456", body)
+	r := bufio.NewReader(strings.NewReader(header + "\n\n"))
+	mh, err := textproto.NewReader(r).ReadMIMEHeader()
+	require.NoError(t, err)
+	subject, err := new(mime.WordDecoder).DecodeHeader(mh.Get("Subject"))
+	require.NoError(t, err)
+	assert.Equal(t, "webook 验证码", subject)
+	assert.Equal(t, "123@qq.com", mh.Get("To"))
+}
+
+// newSilentServer 接受连接之后一直不说话, 返回监听的地址
+func newSilentServer(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Read(make([]byte, 1))
+	}()
+	return ln.Addr().String()
+}
+
+func TestService_SendTimeout(t *testing.T) {
+	svc, err := NewService(newSilentServer(t), "", "", "noreply@webook.com")
+	require.NoError(t, err)
+	svc.Timeout(time.Millisecond * 50)
+	start := time.Now()
+	err = svc.Send(context.Background(), "123@qq.com", "webook 验证码", "你的验证码是 123456")
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestService_SendCanceled(t *testing.T) {
+	svc, err := NewService(newSilentServer(t), "", "", "noreply@webook.com")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(time.Millisecond*50, cancel)
+	start := time.Now()
+	err = svc.Send(ctx, "123@qq.com", "webook 验证码", "你的验证码是 123456")
+	assert.Error(t, err)
+	// 没有等到默认的超时时间
+	assert.Less(t, time.Since(start), time.Second)
+}
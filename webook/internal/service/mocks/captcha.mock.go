@@ -0,0 +1,86 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/captcha.go
+//
+// Generated by this command:
+//
+//	mockgen -source=webook/internal/service/captcha.go -package=svcmocks -destination=webook/internal/service/mocks/captcha.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	domain "basic_go/webook/internal/domain"
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockCaptchaService is a mock of CaptchaService interface.
+type MockCaptchaService struct {
+	ctrl     *gomock.Controller
+	recorder *MockCaptchaServiceMockRecorder
+}
+
+// MockCaptchaServiceMockRecorder is the mock recorder for MockCaptchaService.
+type MockCaptchaServiceMockRecorder struct {
+	mock *MockCaptchaService
+}
+
+// NewMockCaptchaService creates a new mock instance.
+func NewMockCaptchaService(ctrl *gomock.Controller) *MockCaptchaService {
+	mock := &MockCaptchaService{ctrl: ctrl}
+	mock.recorder = &MockCaptchaServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockCaptchaService) EXPECT() *MockCaptchaServiceMockRecorder {
+	return m.recorder
+}
+
+// ConsumePass mocks base method.
+func (m *MockCaptchaService) ConsumePass(ctx context.Context, token string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConsumePass", ctx, token)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ConsumePass indicates an expected call of ConsumePass.
+func (mr *MockCaptchaServiceMockRecorder) ConsumePass(ctx, token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConsumePass", reflect.TypeOf((*MockCaptchaService)(nil).ConsumePass), ctx, token)
+}
+
+// Generate mocks base method.
+func (m *MockCaptchaService) Generate(ctx context.Context, kind domain.CaptchaKind) (domain.Captcha, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Generate", ctx, kind)
+	ret0, _ := ret[0].(domain.Captcha)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Generate indicates an expected call of Generate.
+func (mr *MockCaptchaServiceMockRecorder) Generate(ctx, kind any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Generate", reflect.TypeOf((*MockCaptchaService)(nil).Generate), ctx, kind)
+}
+
+// Verify mocks base method.
+func (m *MockCaptchaService) Verify(ctx context.Context, id, answer string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", ctx, id, answer)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockCaptchaServiceMockRecorder) Verify(ctx, id, answer any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCaptchaService)(nil).Verify), ctx, id, answer)
+}
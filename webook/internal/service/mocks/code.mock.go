@@ -40,30 +40,30 @@ func (m *MockCodeService) EXPECT() *MockCodeServiceMockRecorder {
 }
 
 // Send mocks base method.
-func (m *MockCodeService) Send(ctx context.Context, biz, phone string) error {
+func (m *MockCodeService) Send(ctx context.Context, biz, target string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", ctx, biz, phone)
+	ret := m.ctrl.Call(m, "Send", ctx, biz, target)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockCodeServiceMockRecorder) Send(ctx, biz, phone any) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) Send(ctx, biz, target any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, biz, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), ctx, biz, target)
 }
 
 // Verify mocks base method.
-func (m *MockCodeService) Verify(ctx context.Context, biz, phone, inputCode string) (bool, error) {
+func (m *MockCodeService) Verify(ctx context.Context, biz, target, inputCode string) (bool, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Verify", ctx, biz, phone, inputCode)
+	ret := m.ctrl.Call(m, "Verify", ctx, biz, target, inputCode)
 	ret0, _ := ret[0].(bool)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Verify indicates an expected call of Verify.
-func (mr *MockCodeServiceMockRecorder) Verify(ctx, biz, phone, inputCode any) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) Verify(ctx, biz, target, inputCode any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeService)(nil).Verify), ctx, biz, phone, inputCode)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeService)(nil).Verify), ctx, biz, target, inputCode)
 }
@@ -0,0 +1,71 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/sms_log.go
+//
+// Generated by this command:
+//
+//	mockgen -source=webook/internal/service/sms_log.go -package=svcmocks -destination=webook/internal/service/mocks/sms_log.mock.go
+//
+
+// Package svcmocks is a generated GoMock package.
+package svcmocks
+
+import (
+	domain "basic_go/webook/internal/domain"
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockSMSLogService is a mock of SMSLogService interface.
+type MockSMSLogService struct {
+	ctrl     *gomock.Controller
+	recorder *MockSMSLogServiceMockRecorder
+}
+
+// MockSMSLogServiceMockRecorder is the mock recorder for MockSMSLogService.
+type MockSMSLogServiceMockRecorder struct {
+	mock *MockSMSLogService
+}
+
+// NewMockSMSLogService creates a new mock instance.
+func NewMockSMSLogService(ctrl *gomock.Controller) *MockSMSLogService {
+	mock := &MockSMSLogService{ctrl: ctrl}
+	mock.recorder = &MockSMSLogServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSMSLogService) EXPECT() *MockSMSLogServiceMockRecorder {
+	return m.recorder
+}
+
+// Stats mocks base method.
+func (m *MockSMSLogService) Stats(ctx context.Context, start, end time.Time) ([]domain.SMSStat, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx, start, end)
+	ret0, _ := ret[0].([]domain.SMSStat)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockSMSLogServiceMockRecorder) Stats(ctx, start, end any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockSMSLogService)(nil).Stats), ctx, start, end)
+}
+
+// UpdateDelivery mocks base method.
+func (m *MockSMSLogService) UpdateDelivery(ctx context.Context, d domain.SMSDelivery) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDelivery", ctx, d)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDelivery indicates an expected call of UpdateDelivery.
+func (mr *MockSMSLogServiceMockRecorder) UpdateDelivery(ctx, d any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDelivery", reflect.TypeOf((*MockSMSLogService)(nil).UpdateDelivery), ctx, d)
+}
@@ -0,0 +1,146 @@
+package aliyun
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const defaultEndpoint = "https://dysmsapi.aliyuncs.com"
+
+// Template 阿里云的模板参数是有名字的, Params 按顺序对应 Send 里的 args
+type Template struct {
+	Code   string
+	Params []string
+}
+
+// Service 直接调阿里云短信的 HTTP 接口, 签名算法是 RPC 风格的 HMAC-SHA1
+type Service struct {
+	client          *http.Client
+	endpoint        string
+	accessKeyId     string
+	accessKeySecret string
+	signName        string
+	// templates 逻辑模板 id -> 阿里云模板
+	templates map[string]Template
+}
+
+// NewService endpoint 为空就用阿里云的默认地址
+func NewService(client *http.Client, endpoint string, accessKeyId string, accessKeySecret string,
+	signName string, templates map[string]Template) *Service {
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+	return &Service{
+		client:          client,
+		endpoint:        endpoint,
+		accessKeyId:     accessKeyId,
+		accessKeySecret: accessKeySecret,
+		signName:        signName,
+		templates:       templates,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	_, err := s.SendWithReceipt(ctx, tplId, args, numbers...)
+	return err
+}
+
+// SendWithReceipt 回执是阿里云的 BizId, 一次请求里的所有号码共用一个
+func (s *Service) SendWithReceipt(ctx context.Context, tplId string, args []string, numbers ...string) (string, error) {
+	tpl, ok := s.templates[tplId]
+	if !ok {
+		return "", fmt.Errorf("阿里云短信没有配置模板 %s", tplId)
+	}
+	if len(tpl.Params) != len(args) {
+		return "", fmt.Errorf("模板 %s 需要 %d 个参数, 实际是 %d 个", tplId, len(tpl.Params), len(args))
+	}
+	tplParam := make(map[string]string, len(args))
+	for i, name := range tpl.Params {
+		tplParam[name] = args[i]
+	}
+	tplParamJSON, err := json.Marshal(tplParam)
+	if err != nil {
+		return "", err
+	}
+	params := map[string]string{
+		"AccessKeyId":      s.accessKeyId,
+		"Action":           "SendSms",
+		"Format":           "JSON",
+		"RegionId":         "cn-hangzhou",
+		"SignatureMethod":  "HMAC-SHA1",
+		"SignatureNonce":   uuid.New().String(),
+		"SignatureVersion": "1.0",
+		"Timestamp":        time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		"Version":          "2017-05-25",
+		"PhoneNumbers":     strings.Join(numbers, ","),
+		"SignName":         s.signName,
+		"TemplateCode":     tpl.Code,
+		"TemplateParam":    string(tplParamJSON),
+	}
+	query := canonicalize(params)
+	signature := sign(s.accessKeySecret, http.MethodGet, query)
+	reqURL := s.endpoint + "/?Signature=" + percentEncode(signature) + "&" + query
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var res struct {
+		Code      string
+		Message   string
+		RequestId string
+		BizId     string
+	}
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return "", fmt.Errorf("解析阿里云响应失败, http 状态码 %d: %w", resp.StatusCode, err)
+	}
+	if res.Code != "OK" {
+		return "", fmt.Errorf("发送短信失败 code: %s, msg: %s, request id: %s", res.Code, res.Message, res.RequestId)
+	}
+	return res.BizId, nil
+}
+
+// canonicalize 参数按照名字排序之后拼起来, 名字和值都要 percentEncode
+func canonicalize(params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, percentEncode(k)+"="+percentEncode(params[k]))
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sign(secret string, method string, canonicalQuery string) string {
+	stringToSign := method + "&" + percentEncode("/") + "&" + percentEncode(canonicalQuery)
+	mac := hmac.New(sha1.New, []byte(secret+"&"))
+	mac.Write([]byte(stringToSign))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// percentEncode 阿里云要求的编码, 和 url.QueryEscape 的区别是空格、* 和 ~ 的处理
+func percentEncode(s string) string {
+	res := url.QueryEscape(s)
+	res = strings.ReplaceAll(res, "+", "%20")
+	res = strings.ReplaceAll(res, "*", "%2A")
+	res = strings.ReplaceAll(res, "%7E", "~")
+	return res
+}
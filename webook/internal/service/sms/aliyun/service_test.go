@@ -0,0 +1,105 @@
+package aliyun
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestService_Send(t *testing.T) {
+	templates := map[string]Template{
+		"verify_code": {Code: "SMS_123", Params: []string{"code"}},
+	}
+	testCases := []struct {
+		name    string
+		tplId   string
+		args    []string
+		resp    string
+		wantErr string
+		// wantBizId 阿里云返回的回执
+		wantBizId string
+		// wantReq 为 false 的时候请求根本不会发出去
+		wantReq bool
+	}{
+		{
+			name:      "发送成功",
+			tplId:     "verify_code",
+			args:      []string{"123456"},
+			resp:      `{"Code":"OK","Message":"OK","RequestId":"req-1","BizId":"biz-1"}`,
+			wantBizId: "biz-1",
+			wantReq:   true,
+		},
+		{
+			name:    "阿里云返回错误",
+			tplId:   "verify_code",
+			args:    []string{"123456"},
+			resp:    `{"Code":"isv.BUSINESS_LIMIT_CONTROL","Message":"触发分钟级流控","RequestId":"req-2"}`,
+			wantErr: "发送短信失败 code: isv.BUSINESS_LIMIT_CONTROL, msg: 触发分钟级流控, request id: req-2",
+			wantReq: true,
+		},
+		{
+			name:    "没有配置模板",
+			tplId:   "unknown",
+			args:    []string{"123456"},
+			wantErr: "阿里云短信没有配置模板 unknown",
+		},
+		{
+			name:    "参数个数不对",
+			tplId:   "verify_code",
+			args:    []string{"123456", "10"},
+			wantErr: "模板 verify_code 需要 1 个参数, 实际是 2 个",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requested := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+				query := r.URL.Query()
+				// 服务端用同样的算法重新算一遍签名
+				params := make(map[string]string)
+				for k := range query {
+					if k != "Signature" {
+						params[k] = query.Get(k)
+					}
+				}
+				assert.Equal(t, sign("secret", http.MethodGet, canonicalize(params)), query.Get("Signature"))
+				assert.Equal(t, "key", query.Get("AccessKeyId"))
+				assert.Equal(t, "SendSms", query.Get("Action"))
+				assert.Equal(t, "SMS_123", query.Get("TemplateCode"))
+				assert.Equal(t, "webook", query.Get("SignName"))
+				assert.Equal(t, "13800000000,13900000000", query.Get("PhoneNumbers"))
+				var tplParam map[string]string
+				require.NoError(t, json.Unmarshal([]byte(query.Get("TemplateParam")), &tplParam))
+				assert.Equal(t, map[string]string{"code": "123456"}, tplParam)
+				_, _ = w.Write([]byte(tc.resp))
+			}))
+			defer server.Close()
+
+			svc := NewService(server.Client(), server.URL, "key", "secret", "webook", templates)
+			bizId, err := svc.SendWithReceipt(context.Background(), tc.tplId, tc.args, "13800000000", "13900000000")
+			assert.Equal(t, tc.wantBizId, bizId)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.wantErr)
+			}
+			assert.Equal(t, tc.wantReq, requested)
+		})
+	}
+}
+
+func TestPercentEncode(t *testing.T) {
+	assert.Equal(t, "a%20b%2Ac~d%2F", percentEncode("a b*c~d/"))
+	// 阿里云文档里的例子
+	assert.Equal(t, "AccessKeyId=testid&Action=DescribeRegions",
+		canonicalize(map[string]string{"Action": "DescribeRegions", "AccessKeyId": "testid"}))
+	assert.Equal(t, "OLeaidS1JvxuMvnyHOwuJ+uX5qY=",
+		sign("testsecret", http.MethodGet, "AccessKeyId=testid&Action=DescribeRegions&Format=XML"+
+			"&SignatureMethod=HMAC-SHA1&SignatureNonce=3ee8c1b8-83d3-44af-a94f-4e0ad82fd6cf"+
+			"&SignatureVersion=1.0&Timestamp=2016-02-23T12%3A46%3A24Z&Version=2014-05-26"))
+}
@@ -0,0 +1,181 @@
+package async
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// Service 所有短信都先存到数据库里, 马上返回给调用方, 后台的 worker 去发,
+// 服务商失败或者被全局限流了, 按照指数退避重试, 超过最大次数就放弃
+// 全局限流要套在 svc 里面, 被限流的才会排队等着重试; 按手机号和 IP 的限流要套在外面, 直接告诉调用方
+type Service struct {
+	svc  sms.Service
+	repo repository.AsyncSMSRepository
+
+	// retryMax 第一次发送之外最多重试几次
+	retryMax int
+	// backoff 第一次重试的间隔, 之后每次翻倍, 最多 maxBackoff
+	backoff    time.Duration
+	maxBackoff time.Duration
+	// interval 没有要发的短信的时候, 隔多久再查一次, 本实例新存进来的会马上唤醒 worker
+	interval time.Duration
+	// sendTimeout failover 会依次尝试所有服务商, 要比服务商那一层的超时加起来长
+	sendTimeout time.Duration
+	// lease 抢占一条短信之后, 多久之内别的实例拿不到
+	lease   time.Duration
+	workers int
+
+	notify    chan struct{}
+	startOnce sync.Once
+	closeOnce sync.Once
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+func NewService(svc sms.Service, repo repository.AsyncSMSRepository, retryMax int) *Service {
+	return &Service{
+		svc:         svc,
+		repo:        repo,
+		retryMax:    retryMax,
+		backoff:     time.Second * 10,
+		maxBackoff:  time.Minute * 2,
+		interval:    time.Second,
+		sendTimeout: time.Second * 10,
+		lease:       time.Minute,
+		workers:     4,
+		notify:      make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Workers 同时发送的 worker 数量, 服务商慢的时候一个 worker 会让后面的短信都等着
+func (s *Service) Workers(n int) *Service {
+	s.workers = n
+	return s
+}
+
+// Send 只有存数据库失败的时候才返回错误
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	err := s.repo.Add(ctx, domain.AsyncSMS{
+		TplId:    tplId,
+		Args:     args,
+		Numbers:  numbers,
+		RetryMax: s.retryMax,
+	}, time.Now())
+	if err != nil {
+		return err
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Start 启动后台发送, 重复调用只会启动一次
+func (s *Service) Start() {
+	s.startOnce.Do(func() {
+		var wg sync.WaitGroup
+		for i := 0; i < s.workers; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.loop()
+			}()
+		}
+		go func() {
+			wg.Wait()
+			close(s.done)
+		}()
+	})
+}
+
+// Close 通知 worker 退出, 并且等正在发的那一条发完, 或者 ctx 超时
+func (s *Service) Close(ctx context.Context) error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	// 没有 Start 过也能正常关闭
+	s.startOnce.Do(func() {
+		close(s.done)
+	})
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Service) loop() {
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.sendTimeout)
+		found := s.retryOne(ctx)
+		cancel()
+		if found {
+			continue
+		}
+		select {
+		case <-s.stop:
+			return
+		case <-s.notify:
+		case <-time.After(s.interval):
+		}
+	}
+}
+
+// retryOne 返回 false 说明暂时没有要发的, 可以歇一会儿
+func (s *Service) retryOne(ctx context.Context) bool {
+	as, err := s.repo.PreemptWaiting(ctx, s.lease)
+	switch {
+	case errors.Is(err, repository.ErrWaitingSMSNotFound):
+		return false
+	case errors.Is(err, repository.ErrAsyncSMSPreempted):
+		return true
+	case err != nil:
+		log.Println("抢占异步短信失败", err)
+		return false
+	}
+	err = s.svc.Send(ctx, as.TplId, as.Args, as.Numbers...)
+	if err == nil {
+		err = s.repo.ReportSuccess(ctx, as.Id)
+		if err != nil {
+			log.Println("更新异步短信状态失败", err)
+		}
+		return true
+	}
+	as.RetryCnt++
+	final := as.RetryCnt > as.RetryMax
+	if final {
+		log.Println("异步短信重试次数用完, 放弃", as.Id, err)
+	}
+	err = s.repo.ReportFailure(ctx, as.Id, as.RetryCnt, time.Now().Add(s.backoffOf(as.RetryCnt-1)), final)
+	if err != nil {
+		log.Println("更新异步短信状态失败", err)
+	}
+	return true
+}
+
+// backoffOf 第 n 次重试之前要等多久, n 从 0 开始
+func (s *Service) backoffOf(n int) time.Duration {
+	d := s.backoff
+	for i := 0; i < n; i++ {
+		d *= 2
+		if d >= s.maxBackoff {
+			return s.maxBackoff
+		}
+	}
+	return d
+}
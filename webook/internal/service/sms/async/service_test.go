@@ -0,0 +1,136 @@
+package async
+
+import (
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMSService 前 failCnt 次返回错误, 之后成功
+type fakeSMSService struct {
+	mu      sync.Mutex
+	failCnt int
+	calls   int
+	// lastArgs 最后一次发送的参数, 重试的时候验证码要原样带上
+	lastArgs []string
+}
+
+func (f *fakeSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	f.lastArgs = args
+	if f.calls <= f.failCnt {
+		return errors.New("发送失败")
+	}
+	return nil
+}
+
+func (f *fakeSMSService) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+func (f *fakeSMSService) LastArgs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.lastArgs
+}
+
+func initDB(t *testing.T) *gorm.DB {
+	db := daotest.NewMemoryDB(t)
+	require.NoError(t, dao.InitTable(db))
+	return db
+}
+
+func TestService_Send(t *testing.T) {
+	testCases := []struct {
+		name         string
+		failCnt      int
+		retryMax     int
+		wantCalls    int
+		wantStatus   uint8
+		wantRetryCnt int
+	}{
+		{
+			name:       "第一次就发送成功",
+			failCnt:    0,
+			retryMax:   3,
+			wantCalls:  1,
+			wantStatus: dao.AsyncSMSStatusSuccess,
+		},
+		{
+			name:         "重试三次之后成功",
+			failCnt:      3,
+			retryMax:     3,
+			wantCalls:    4,
+			wantStatus:   dao.AsyncSMSStatusSuccess,
+			wantRetryCnt: 3,
+		},
+		{
+			name:         "重试次数用完",
+			failCnt:      100,
+			retryMax:     2,
+			wantCalls:    3,
+			wantStatus:   dao.AsyncSMSStatusFailed,
+			wantRetryCnt: 3,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := initDB(t)
+			provider := &fakeSMSService{failCnt: tc.failCnt}
+			svc := NewService(provider, repository.NewAsyncSMSRepository(dao.NewAsyncSMSDAO(db)), tc.retryMax)
+			svc.backoff = time.Millisecond
+			svc.maxBackoff = time.Millisecond * 4
+			svc.interval = time.Millisecond * 5
+
+			err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+			require.NoError(t, err)
+			// 存进数据库就返回了, 还没有调用服务商
+			assert.Zero(t, provider.Calls())
+			svc.Start()
+			defer func() {
+				assert.NoError(t, svc.Close(context.Background()))
+			}()
+
+			var s dao.AsyncSMS
+			require.Eventually(t, func() bool {
+				err := db.First(&s).Error
+				return err == nil && s.Status == tc.wantStatus
+			}, time.Second*5, time.Millisecond*10)
+			assert.Equal(t, tc.wantRetryCnt, s.RetryCnt)
+			// 不再重试了, 验证码就不留在库里
+			assert.Empty(t, s.Args)
+			assert.Equal(t, `["13800000000"]`, s.Numbers)
+			assert.Equal(t, tc.wantCalls, provider.Calls())
+			assert.Equal(t, []string{"123456"}, provider.LastArgs())
+		})
+	}
+}
+
+func TestService_backoffOf(t *testing.T) {
+	svc := &Service{backoff: time.Second, maxBackoff: time.Second * 5}
+	assert.Equal(t, time.Second, svc.backoffOf(0))
+	assert.Equal(t, time.Second*2, svc.backoffOf(1))
+	assert.Equal(t, time.Second*4, svc.backoffOf(2))
+	assert.Equal(t, time.Second*5, svc.backoffOf(3))
+	assert.Equal(t, time.Second*5, svc.backoffOf(100))
+}
+
+func TestService_CloseWithoutStart(t *testing.T) {
+	svc := NewService(&fakeSMSService{}, nil, 3)
+	assert.NoError(t, svc.Close(context.Background()))
+	// 关闭之后再 Start 不会再启动 worker
+	svc.Start()
+	assert.NoError(t, svc.Close(context.Background()))
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"log"
+	"time"
+)
+
+// Service 记录每一次调用服务商的结果, 套在具体的服务商外面, 这样 failover 和重试的每一次尝试都有记录
+// 服务商实现了 sms.ReceiptService 的话, 还会记下回执 id, 等回调的时候更新送达状态
+type Service struct {
+	svc      sms.Service
+	provider string
+	repo     repository.SMSLogRepository
+}
+
+func NewService(svc sms.Service, provider string, repo repository.SMSLogRepository) *Service {
+	return &Service{
+		svc:      svc,
+		provider: provider,
+		repo:     repo,
+	}
+}
+
+func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	start := time.Now()
+	var bizId string
+	var err error
+	if rs, ok := s.svc.(sms.ReceiptService); ok {
+		bizId, err = rs.SendWithReceipt(ctx, tplId, args, numbers...)
+	} else {
+		err = s.svc.Send(ctx, tplId, args, numbers...)
+	}
+	l := domain.SMSLog{
+		Provider: s.provider,
+		TplId:    tplId,
+		BizId:    bizId,
+		Phones:   make([]string, 0, len(numbers)),
+		Success:  err == nil,
+		Latency:  time.Since(start),
+		Ctime:    start,
+	}
+	if err != nil {
+		l.Err = err.Error()
+	}
+	for _, number := range numbers {
+		l.Phones = append(l.Phones, maskPhone(number))
+	}
+	// 调用方超时了也要记下来, 这种往往就是要排查的
+	logCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+	defer cancel()
+	// 记录失败不影响发短信
+	if er := s.repo.Add(logCtx, l); er != nil {
+		log.Println("记录短信发送日志失败", er)
+	}
+	return err
+}
+
+// maskPhone 只保留前三位和后四位, 13812345678 -> 138****5678
+func maskPhone(phone string) string {
+	if len(phone) < 8 {
+		return "****"
+	}
+	return phone[:3] + "****" + phone[len(phone)-4:]
+}
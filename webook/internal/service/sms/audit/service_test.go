@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	smsmocks "basic_go/webook/internal/service/sms/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func initDB(t *testing.T) *gorm.DB {
+	db := daotest.NewMemoryDB(t)
+	require.NoError(t, dao.InitTable(db))
+	return db
+}
+
+func TestService_Send(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := initDB(t)
+	repo := repository.NewSMSLogRepository(dao.NewSMSLogDAO(db))
+
+	tencent := smsmocks.NewMockService(ctrl)
+	tencent.EXPECT().Send(gomock.Any(), "verify_code", []string{"123456"}, "13812345678").Return(nil)
+	tencent.EXPECT().Send(gomock.Any(), "verify_code", []string{"654321"}, "13812345678").
+		Return(errors.New("余额不足"))
+	aliyun := smsmocks.NewMockService(ctrl)
+	aliyun.EXPECT().Send(gomock.Any(), "verify_code", []string{"654321"}, "13812345678").Return(nil)
+
+	tsvc := NewService(tencent, "tencent", repo)
+	asvc := NewService(aliyun, "aliyun", repo)
+	ctx := context.Background()
+	require.NoError(t, tsvc.Send(ctx, "verify_code", []string{"123456"}, "13812345678"))
+	// 失败的错误原样返回
+	assert.Equal(t, errors.New("余额不足"), tsvc.Send(ctx, "verify_code", []string{"654321"}, "13812345678"))
+	require.NoError(t, asvc.Send(ctx, "verify_code", []string{"654321"}, "13812345678"))
+
+	var logs []dao.SMSLog
+	require.NoError(t, db.Order("id").Find(&logs).Error)
+	require.Len(t, logs, 3)
+	// 手机号打码, 不保存验证码
+	assert.Equal(t, "138****5678", logs[0].Phones)
+	assert.Equal(t, dao.SMSLogStatusSuccess, logs[0].Status)
+	assert.Equal(t, dao.SMSLogStatusFailed, logs[1].Status)
+	assert.Equal(t, "余额不足", logs[1].Err)
+
+	now := time.Now()
+	stats, err := repo.Stats(ctx, now, now)
+	require.NoError(t, err)
+	for i := range stats {
+		stats[i].AvgLatency = 0
+	}
+	day := now.Format("2006-01-02")
+	assert.Equal(t, []domain.SMSStat{
+		{Day: day, Provider: "aliyun", Total: 1},
+		{Day: day, Provider: "tencent", Total: 2, Failed: 1},
+	}, stats)
+	assert.Equal(t, 0.5, stats[1].FailureRate())
+
+	// 不在范围里面的日期查不到
+	stats, err = repo.Stats(ctx, now.AddDate(0, 0, -3), now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+}
+
+func TestService_SendWithReceipt(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	db := initDB(t)
+	repo := repository.NewSMSLogRepository(dao.NewSMSLogDAO(db))
+
+	aliyun := smsmocks.NewMockReceiptService(ctrl)
+	aliyun.EXPECT().SendWithReceipt(gomock.Any(), "verify_code", []string{"123456"}, "13812345678").
+		Return("biz-1", nil)
+	aliyun.EXPECT().SendWithReceipt(gomock.Any(), "verify_code", []string{"654321"}, "13812345678", "13912345678").
+		Return("biz-2", nil)
+	svc := NewService(aliyun, "aliyun", repo)
+	ctx := context.Background()
+	require.NoError(t, svc.Send(ctx, "verify_code", []string{"123456"}, "13812345678"))
+	require.NoError(t, svc.Send(ctx, "verify_code", []string{"654321"}, "13812345678", "13912345678"))
+
+	reportTime := time.Now()
+	require.NoError(t, repo.UpdateDelivery(ctx, domain.SMSDelivery{
+		Provider: "aliyun", BizId: "biz-1", Delivered: true, ReportTime: reportTime,
+	}))
+	// 一次发了两个号码, 有一个没送达就算没送达, 后面再来送达的也不覆盖
+	require.NoError(t, repo.UpdateDelivery(ctx, domain.SMSDelivery{
+		Provider: "aliyun", BizId: "biz-2", Err: "MOBILE_NOT_ON_SERVICE 停机", ReportTime: reportTime,
+	}))
+	require.NoError(t, repo.UpdateDelivery(ctx, domain.SMSDelivery{
+		Provider: "aliyun", BizId: "biz-2", Delivered: true, ReportTime: reportTime,
+	}))
+	// 别的服务商的同一个回执 id 不会更新到这里
+	require.NoError(t, repo.UpdateDelivery(ctx, domain.SMSDelivery{
+		Provider: "tencent", BizId: "biz-1", Err: "失败", ReportTime: reportTime,
+	}))
+
+	var logs []dao.SMSLog
+	require.NoError(t, db.Order("id").Find(&logs).Error)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "biz-1", logs[0].BizId)
+	assert.Equal(t, dao.SMSLogDeliveryDelivered, logs[0].Delivery)
+	assert.Equal(t, reportTime.UnixMilli(), logs[0].DeliveryTime)
+	assert.Equal(t, dao.SMSLogDeliveryUndelivered, logs[1].Delivery)
+	assert.Equal(t, "MOBILE_NOT_ON_SERVICE 停机", logs[1].DeliveryErr)
+
+	stats, err := repo.Stats(ctx, reportTime, reportTime)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.Equal(t, int64(1), stats[0].Delivered)
+	assert.Equal(t, int64(1), stats[0].Undelivered)
+}
+
+// failingRepo 记录失败不能影响发短信
+type failingRepo struct {
+	repository.SMSLogRepository
+}
+
+func (r failingRepo) Add(ctx context.Context, l domain.SMSLog) error {
+	return errors.New("db error")
+}
+
+func TestService_SendLogFailed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc := smsmocks.NewMockService(ctrl)
+	svc.EXPECT().Send(gomock.Any(), "verify_code", []string{"123456"}, "13812345678").Return(nil)
+	err := NewService(svc, "tencent", failingRepo{}).
+		Send(context.Background(), "verify_code", []string{"123456"}, "13812345678")
+	assert.NoError(t, err)
+}
+
+func TestMaskPhone(t *testing.T) {
+	assert.Equal(t, "138****5678", maskPhone("13812345678"))
+	assert.Equal(t, "+86****5678", maskPhone("+8613812345678"))
+	assert.Equal(t, "****", maskPhone("1234"))
+}
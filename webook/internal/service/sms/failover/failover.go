@@ -0,0 +1,61 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// defaultTimeout 单个服务商一次发送最多等多久, 超时了就换下一个
+const defaultTimeout = time.Second * 3
+
+var ErrAllFailed = errors.New("所有短信服务商都发送失败")
+
+// FailoverSMSService 轮询, 每次从下一个服务商开始, 失败了就换下一个,
+// 直到有一个发送成功或者全部都试过一遍
+type FailoverSMSService struct {
+	svcs    []sms.Service
+	idx     uint64
+	timeout time.Duration
+}
+
+// NewFailoverSMSService svcs 不能为空
+func NewFailoverSMSService(svcs []sms.Service) *FailoverSMSService {
+	if len(svcs) == 0 {
+		panic("至少要有一个短信服务商")
+	}
+	return &FailoverSMSService{
+		svcs:    svcs,
+		timeout: defaultTimeout,
+	}
+}
+
+// Timeout 单个服务商一次发送的超时时间
+func (f *FailoverSMSService) Timeout(timeout time.Duration) *FailoverSMSService {
+	f.timeout = timeout
+	return f
+}
+
+func (f *FailoverSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	start := atomic.AddUint64(&f.idx, 1)
+	length := uint64(len(f.svcs))
+	for i := uint64(0); i < length; i++ {
+		svc := f.svcs[(start+i)%length]
+		// 每个服务商单独计时, 一个服务商卡住了不会把后面的机会也耗完
+		attemptCtx, cancel := context.WithTimeout(ctx, f.timeout)
+		err := svc.Send(attemptCtx, tplId, args, numbers...)
+		cancel()
+		if err == nil {
+			return nil
+		}
+		// 调用方已经放弃了, 再换服务商也没有意义
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Println("短信服务商发送失败, 切换下一个", err)
+	}
+	return ErrAllFailed
+}
@@ -0,0 +1,123 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	smsmocks "basic_go/webook/internal/service/sms/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestFailoverSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name    string
+		mock    func(ctrl *gomock.Controller) []sms.Service
+		wantErr error
+	}{
+		{
+			name: "第一个就成功",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				// idx 从 1 开始, 第一次用的是 svc1
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+		},
+		{
+			name: "失败了换下一个",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(errors.New("发送失败"))
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+		},
+		{
+			name: "全部失败",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(errors.New("发送失败"))
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(context.DeadlineExceeded)
+				return []sms.Service{svc0, svc1}
+			},
+			wantErr: ErrAllFailed,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewFailoverSMSService(tc.mock(ctrl))
+			err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestFailoverSMSService_ContextCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx, cancel := context.WithCancel(context.Background())
+	svc0 := smsmocks.NewMockService(ctrl)
+	svc1 := smsmocks.NewMockService(ctrl)
+	svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, tplId string, args []string, numbers ...string) error {
+			cancel()
+			return ctx.Err()
+		})
+	svc := NewFailoverSMSService([]sms.Service{svc0, svc1})
+	err := svc.Send(ctx, "tpl", []string{"123456"}, "13800000000")
+	assert.Equal(t, context.Canceled, err)
+}
+
+// hangingSend 模拟卡住的服务商, 一直等到 ctx 超时
+func hangingSend(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestFailoverSMSService_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc0 := smsmocks.NewMockService(ctrl)
+	svc1 := smsmocks.NewMockService(ctrl)
+	// 第一次用的是 svc1, 它卡住了, 超时之后换 svc0
+	svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(hangingSend)
+	svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+	svc := NewFailoverSMSService([]sms.Service{svc0, svc1}).Timeout(time.Millisecond * 10)
+
+	start := time.Now()
+	err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestFailoverSMSService_CallerCanceled(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc0 := smsmocks.NewMockService(ctrl)
+	svc1 := smsmocks.NewMockService(ctrl)
+	// 调用方自己超时了, 不再换服务商
+	svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(hangingSend)
+	svc := NewFailoverSMSService([]sms.Service{svc0, svc1}).Timeout(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err := svc.Send(ctx, "tpl", []string{"123456"}, "13800000000")
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestNewFailoverSMSService_Empty(t *testing.T) {
+	assert.PanicsWithValue(t, "至少要有一个短信服务商", func() {
+		NewFailoverSMSService(nil)
+	})
+}
@@ -0,0 +1,88 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// TimeoutFailoverSMSService 一直用当前的服务商, 连续超时 threshold 次之后才切到下一个
+// 偶尔超时不切换, 避免服务商之间来回抖动; 这一次发送失败了, 还是会依次试后面的服务商
+type TimeoutFailoverSMSService struct {
+	svcs []sms.Service
+	// idx 当前用的服务商
+	idx int32
+	// cnt 当前服务商连续超时的次数
+	cnt       int32
+	threshold int32
+	timeout   time.Duration
+}
+
+// NewTimeoutFailoverSMSService svcs 不能为空, threshold 要大于 0, 不然每次发送都会切换
+func NewTimeoutFailoverSMSService(svcs []sms.Service, threshold int32) *TimeoutFailoverSMSService {
+	if len(svcs) == 0 {
+		panic("至少要有一个短信服务商")
+	}
+	if threshold <= 0 {
+		panic(fmt.Sprintf("连续超时的阈值要大于 0, 现在是 %d", threshold))
+	}
+	return &TimeoutFailoverSMSService{
+		svcs:      svcs,
+		threshold: threshold,
+		timeout:   defaultTimeout,
+	}
+}
+
+// Timeout 一次发送超过这个时间就算这个服务商超时一次
+func (t *TimeoutFailoverSMSService) Timeout(timeout time.Duration) *TimeoutFailoverSMSService {
+	t.timeout = timeout
+	return t
+}
+
+func (t *TimeoutFailoverSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	idx := atomic.LoadInt32(&t.idx)
+	cnt := atomic.LoadInt32(&t.cnt)
+	if cnt >= t.threshold {
+		newIdx := (idx + 1) % int32(len(t.svcs))
+		// 并发的时候只有一个能切换成功, 切换成功的负责把计数清零
+		if atomic.CompareAndSwapInt32(&t.idx, idx, newIdx) {
+			atomic.StoreInt32(&t.cnt, 0)
+		}
+		idx = atomic.LoadInt32(&t.idx)
+	}
+	length := int32(len(t.svcs))
+	for i := int32(0); i < length; i++ {
+		err := t.sendOnce(ctx, t.svcs[(idx+i)%length], tplId, args, numbers...)
+		// 只有当前的服务商才记录连续超时, 后面的只是这一次临时顶上
+		if i == 0 {
+			switch {
+			case err == nil:
+				// 只要成功一次, 就不算连续超时了
+				atomic.StoreInt32(&t.cnt, 0)
+			// 调用方自己的 deadline 到了不算服务商超时
+			case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+				atomic.AddInt32(&t.cnt, 1)
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		// 调用方已经放弃了, 再换服务商也没有意义
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		log.Println("短信服务商发送失败, 切换下一个", err)
+	}
+	return ErrAllFailed
+}
+
+func (t *TimeoutFailoverSMSService) sendOnce(ctx context.Context, svc sms.Service,
+	tplId string, args []string, numbers ...string) error {
+	attemptCtx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return svc.Send(attemptCtx, tplId, args, numbers...)
+}
@@ -0,0 +1,173 @@
+package failover
+
+import (
+	"basic_go/webook/internal/service/sms"
+	smsmocks "basic_go/webook/internal/service/sms/mocks"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestTimeoutFailoverSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name      string
+		mock      func(ctrl *gomock.Controller) []sms.Service
+		threshold int32
+		idx       int32
+		cnt       int32
+		wantErr   error
+		wantIdx   int32
+		wantCnt   int32
+	}{
+		{
+			name: "没有超过阈值",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			cnt:       2,
+			wantIdx:   0,
+			wantCnt:   0,
+		},
+		{
+			name: "超时计数, 这一次换下一个发",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(context.DeadlineExceeded)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			cnt:       1,
+			wantIdx:   0,
+			wantCnt:   2,
+		},
+		{
+			name: "连续超时达到阈值, 切换",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			cnt:       3,
+			wantIdx:   1,
+			wantCnt:   0,
+		},
+		{
+			name: "最后一个切回第一个",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(context.DeadlineExceeded)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			idx:       1,
+			cnt:       3,
+			wantIdx:   0,
+			wantCnt:   1,
+		},
+		{
+			name: "其它错误不计数",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(errors.New("发送失败"))
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil)
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			cnt:       2,
+			wantIdx:   0,
+			wantCnt:   2,
+		},
+		{
+			name: "全部失败",
+			mock: func(ctrl *gomock.Controller) []sms.Service {
+				svc0 := smsmocks.NewMockService(ctrl)
+				svc1 := smsmocks.NewMockService(ctrl)
+				svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(context.DeadlineExceeded)
+				svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+					Return(errors.New("发送失败"))
+				return []sms.Service{svc0, svc1}
+			},
+			threshold: 3,
+			cnt:       1,
+			wantErr:   ErrAllFailed,
+			wantIdx:   0,
+			wantCnt:   2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			svc := NewTimeoutFailoverSMSService(tc.mock(ctrl), tc.threshold)
+			svc.idx = tc.idx
+			svc.cnt = tc.cnt
+			err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+			assert.Equal(t, tc.wantErr, err)
+			assert.Equal(t, tc.wantIdx, svc.idx)
+			assert.Equal(t, tc.wantCnt, svc.cnt)
+		})
+	}
+}
+
+func TestTimeoutFailoverSMSService_Timeout(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc0 := smsmocks.NewMockService(ctrl)
+	svc1 := smsmocks.NewMockService(ctrl)
+	svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		DoAndReturn(hangingSend).Times(2)
+	svc1.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(nil).Times(3)
+	svc := NewTimeoutFailoverSMSService([]sms.Service{svc0, svc1}, 2).Timeout(time.Millisecond * 10)
+
+	// svc0 超时的那两次由 svc1 顶上, 连续超时两次之后切到 svc1
+	for i := 0; i < 2; i++ {
+		err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+		assert.NoError(t, err)
+		assert.Equal(t, int32(0), svc.idx)
+	}
+	err := svc.Send(context.Background(), "tpl", []string{"123456"}, "13800000000")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), svc.idx)
+}
+
+func TestTimeoutFailoverSMSService_CallerDeadline(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	svc0 := smsmocks.NewMockService(ctrl)
+	svc0.EXPECT().Send(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).DoAndReturn(hangingSend)
+	svc := NewTimeoutFailoverSMSService([]sms.Service{svc0}, 2).Timeout(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	err := svc.Send(ctx, "tpl", []string{"123456"}, "13800000000")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	// 调用方的 deadline 不算服务商超时
+	assert.Equal(t, int32(0), svc.cnt)
+}
+
+func TestNewTimeoutFailoverSMSService_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "至少要有一个短信服务商", func() {
+		NewTimeoutFailoverSMSService(nil, 3)
+	})
+	assert.PanicsWithValue(t, "连续超时的阈值要大于 0, 现在是 0", func() {
+		NewTimeoutFailoverSMSService([]sms.Service{smsmocks.NewMockService(gomock.NewController(t))}, 0)
+	})
+}
@@ -0,0 +1,121 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/service/sms/types.go
+//
+// Generated by this command:
+//
+//	mockgen -source=webook/internal/service/sms/types.go -package=smsmocks -destination=webook/internal/service/sms/mocks/sms.mock.go
+//
+
+// Package smsmocks is a generated GoMock package.
+package smsmocks
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockService is a mock of Service interface.
+type MockService struct {
+	ctrl     *gomock.Controller
+	recorder *MockServiceMockRecorder
+}
+
+// MockServiceMockRecorder is the mock recorder for MockService.
+type MockServiceMockRecorder struct {
+	mock *MockService
+}
+
+// NewMockService creates a new mock instance.
+func NewMockService(ctrl *gomock.Controller) *MockService {
+	mock := &MockService{ctrl: ctrl}
+	mock.recorder = &MockServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockService) EXPECT() *MockServiceMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, tplId, args}
+	for _, a := range numbers {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Send", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockServiceMockRecorder) Send(ctx, tplId, args any, numbers ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, tplId, args}, numbers...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockService)(nil).Send), varargs...)
+}
+
+// MockReceiptService is a mock of ReceiptService interface.
+type MockReceiptService struct {
+	ctrl     *gomock.Controller
+	recorder *MockReceiptServiceMockRecorder
+}
+
+// MockReceiptServiceMockRecorder is the mock recorder for MockReceiptService.
+type MockReceiptServiceMockRecorder struct {
+	mock *MockReceiptService
+}
+
+// NewMockReceiptService creates a new mock instance.
+func NewMockReceiptService(ctrl *gomock.Controller) *MockReceiptService {
+	mock := &MockReceiptService{ctrl: ctrl}
+	mock.recorder = &MockReceiptServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockReceiptService) EXPECT() *MockReceiptServiceMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockReceiptService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, tplId, args}
+	for _, a := range numbers {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "Send", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockReceiptServiceMockRecorder) Send(ctx, tplId, args any, numbers ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, tplId, args}, numbers...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockReceiptService)(nil).Send), varargs...)
+}
+
+// SendWithReceipt mocks base method.
+func (m *MockReceiptService) SendWithReceipt(ctx context.Context, tplId string, args []string, numbers ...string) (string, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{ctx, tplId, args}
+	for _, a := range numbers {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "SendWithReceipt", varargs...)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SendWithReceipt indicates an expected call of SendWithReceipt.
+func (mr *MockReceiptServiceMockRecorder) SendWithReceipt(ctx, tplId, args any, numbers ...any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{ctx, tplId, args}, numbers...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SendWithReceipt", reflect.TypeOf((*MockReceiptService)(nil).SendWithReceipt), varargs...)
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"basic_go/webook/internal/service/sms"
+	"basic_go/webook/pkg/clientip"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"errors"
+	"fmt"
+)
+
+var ErrPhoneLimited = errors.New("这个手机号发送的短信太多")
+var ErrIPLimited = errors.New("这个 IP 发送的短信太多")
+
+// KeyedRateLimitSMSService 按照手机号和客户端 IP 分别限流, 防止一个人把整个短信额度用完
+// 全局限流还是交给 RateLimitSMSService
+type KeyedRateLimitSMSService struct {
+	svc          sms.Service
+	phoneLimiter limiter.Limiter
+	ipLimiter    limiter.Limiter
+}
+
+func NewKeyedRateLimitSMSService(svc sms.Service) *KeyedRateLimitSMSService {
+	return &KeyedRateLimitSMSService{
+		svc: svc,
+	}
+}
+
+// PhoneLimiter 不设置就不按手机号限流
+func (k *KeyedRateLimitSMSService) PhoneLimiter(l limiter.Limiter) *KeyedRateLimitSMSService {
+	k.phoneLimiter = l
+	return k
+}
+
+// IPLimiter IP 从 clientip.FromContext 里拿, 拿不到的时候不限流
+func (k *KeyedRateLimitSMSService) IPLimiter(l limiter.Limiter) *KeyedRateLimitSMSService {
+	k.ipLimiter = l
+	return k
+}
+
+func (k *KeyedRateLimitSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	if ip := clientip.FromContext(ctx); ip != "" && k.ipLimiter != nil {
+		err := k.limit(ctx, k.ipLimiter, fmt.Sprintf("sms-limiter:ip:%s", ip), ErrIPLimited)
+		if err != nil {
+			return err
+		}
+	}
+	if k.phoneLimiter != nil {
+		for _, number := range numbers {
+			err := k.limit(ctx, k.phoneLimiter, fmt.Sprintf("sms-limiter:phone:%s", number), ErrPhoneLimited)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return k.svc.Send(ctx, tplId, args, numbers...)
+}
+
+func (k *KeyedRateLimitSMSService) limit(ctx context.Context, l limiter.Limiter, key string, limitedErr error) error {
+	res, err := l.Limit(ctx, key)
+	if err != nil {
+		return err
+	}
+	if res.Limited {
+		return limitedErr
+	}
+	return nil
+}
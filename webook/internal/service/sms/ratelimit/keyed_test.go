@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"basic_go/webook/internal/service/sms"
+	smsmocks "basic_go/webook/internal/service/sms/mocks"
+	"basic_go/webook/pkg/clientip"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestKeyedRateLimitSMSService_Send(t *testing.T) {
+	type send struct {
+		ip      string
+		phone   string
+		wantErr error
+	}
+	testCases := []struct {
+		name  string
+		sends []send
+	}{
+		{
+			name: "同一个手机号超过每天的上限",
+			sends: []send{
+				{ip: "10.0.0.1", phone: "13800000000"},
+				{ip: "10.0.0.2", phone: "13800000000"},
+				{ip: "10.0.0.3", phone: "13800000000", wantErr: ErrPhoneLimited},
+				{ip: "10.0.0.3", phone: "13900000000"},
+			},
+		},
+		{
+			name: "同一个 IP 换着手机号发",
+			sends: []send{
+				{ip: "10.0.0.1", phone: "13800000001"},
+				{ip: "10.0.0.1", phone: "13800000002"},
+				{ip: "10.0.0.1", phone: "13800000003"},
+				{ip: "10.0.0.1", phone: "13800000004", wantErr: ErrIPLimited},
+				{ip: "10.0.0.2", phone: "13800000004"},
+			},
+		},
+		{
+			name: "拿不到 IP 只按手机号限流",
+			sends: []send{
+				{phone: "13800000001"},
+				{phone: "13800000002"},
+				{phone: "13800000003"},
+				{phone: "13800000004"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			provider := smsmocks.NewMockService(ctrl)
+			var svc sms.Service = NewKeyedRateLimitSMSService(provider).
+				PhoneLimiter(limiter.NewRedisSlidingWindowLimiter(rdb, time.Hour*24, 2)).
+				IPLimiter(limiter.NewRedisSlidingWindowLimiter(rdb, time.Hour, 3))
+			for _, s := range tc.sends {
+				if s.wantErr == nil {
+					provider.EXPECT().Send(gomock.Any(), "tpl", []string{"123456"}, s.phone).Return(nil)
+				}
+				ctx := context.Background()
+				if s.ip != "" {
+					ctx = clientip.WithIP(ctx, s.ip)
+				}
+				err := svc.Send(ctx, "tpl", []string{"123456"}, s.phone)
+				require.Equal(t, s.wantErr, err)
+			}
+		})
+	}
+}
@@ -7,7 +7,8 @@ import (
 	"errors"
 )
 
-var errLimited = errors.New("触发限流")
+// ErrLimited 触发了全局限流
+var ErrLimited = errors.New("触发限流")
 
 type RateLimitSMSService struct {
 	svc     sms.Service
@@ -24,12 +25,12 @@ func NewRateLimitSMSService(svc sms.Service, l limiter.Limiter) *RateLimitSMSSer
 }
 
 func (r *RateLimitSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
-	limited, err := r.limiter.Limit(ctx, r.key)
+	res, err := r.limiter.Limit(ctx, r.key)
 	if err != nil {
 		return err
 	}
-	if limited {
-		return errLimited
+	if res.Limited {
+		return ErrLimited
 	}
 	return r.svc.Send(ctx, tplId, args, numbers...)
 }
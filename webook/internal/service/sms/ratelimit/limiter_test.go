@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	smsmocks "basic_go/webook/internal/service/sms/mocks"
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSMSService_Send(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limiter func(rdb redis.Cmdable) limiter.Limiter
+	}{
+		{
+			name: "滑动窗口",
+			limiter: func(rdb redis.Cmdable) limiter.Limiter {
+				return limiter.NewRedisSlidingWindowLimiter(rdb, time.Minute, 2)
+			},
+		},
+		{
+			name: "令牌桶",
+			limiter: func(rdb redis.Cmdable) limiter.Limiter {
+				return limiter.NewRedisTokenBucketLimiter(rdb, 2, time.Minute, 1)
+			},
+		},
+		{
+			name: "固定窗口",
+			limiter: func(rdb redis.Cmdable) limiter.Limiter {
+				return limiter.NewRedisFixedWindowLimiter(rdb, time.Minute, 2)
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			smsSvc := smsmocks.NewMockService(ctrl)
+			smsSvc.EXPECT().Send(gomock.Any(), "verify_code", []string{"123456"}, "13800000000").
+				Return(nil).Times(2)
+			svc := NewRateLimitSMSService(smsSvc, tc.limiter(rdb))
+			for i := 0; i < 2; i++ {
+				assert.NoError(t, svc.Send(context.Background(), "verify_code", []string{"123456"}, "13800000000"))
+			}
+			assert.Equal(t, ErrLimited, svc.Send(context.Background(), "verify_code", []string{"123456"}, "13800000000"))
+		})
+	}
+}
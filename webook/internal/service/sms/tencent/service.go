@@ -12,31 +12,50 @@ type Service struct {
 	client   *sms.Client
 	appId    *string
 	signName *string
+	// templates 逻辑模板 id -> 腾讯云的模板 id, 没配置的直接当成腾讯云的模板 id
+	templates map[string]string
 }
 
 func (s *Service) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	_, err := s.SendWithReceipt(ctx, tplId, args, numbers...)
+	return err
+}
+
+// SendWithReceipt 回执是腾讯云的 SerialNo, 每个号码有自己的 SerialNo,
+// 验证码只发给一个号码, 所以只返回第一个
+func (s *Service) SendWithReceipt(ctx context.Context, tplId string, args []string, numbers ...string) (string, error) {
 	request := sms.NewSendSmsRequest()
 	request.SetContext(ctx)
 	request.SmsSdkAppId = s.appId
 	request.SignName = s.signName
-	request.TemplateId = ekit.ToPtr[string](tplId)
+	request.TemplateId = ekit.ToPtr[string](s.templateId(tplId))
 	request.TemplateParamSet = s.toPtrSlice(args)
 	request.PhoneNumberSet = s.toPtrSlice(numbers)
 	response, err := s.client.SendSms(request)
 	if err != nil {
-		return err
+		return "", err
 	}
+	var serialNo string
 	for _, statusPtr := range response.Response.SendStatusSet {
 		if statusPtr == nil {
 			continue
 		}
 		status := *statusPtr
 		if status.Code == nil || *(status.Code) != "Ok" {
-			return fmt.Errorf("发送短信失败 code: %s, msg: %s", *status.Code, *status.Message)
+			return "", fmt.Errorf("发送短信失败 code: %s, msg: %s", *status.Code, *status.Message)
+		}
+		if serialNo == "" && status.SerialNo != nil {
+			serialNo = *status.SerialNo
 		}
 	}
-	return nil
+	return serialNo, nil
+}
 
+func (s *Service) templateId(tplId string) string {
+	if id, ok := s.templates[tplId]; ok {
+		return id
+	}
+	return tplId
 }
 
 func (s *Service) toPtrSlice(data []string) []*string {
@@ -45,10 +64,11 @@ func (s *Service) toPtrSlice(data []string) []*string {
 	})
 }
 
-func NewService(client *sms.Client, appId string, sigName string) *Service {
+func NewService(client *sms.Client, appId string, sigName string, templates map[string]string) *Service {
 	return &Service{
-		client:   client,
-		appId:    &appId,
-		signName: &sigName,
+		client:    client,
+		appId:     &appId,
+		signName:  &sigName,
+		templates: templates,
 	}
 }
@@ -26,7 +26,7 @@ func TestSendet(t *testing.T) {
 		t.Fatal()
 	}
 
-	s := NewService(c, "111111111", "abc")
+	s := NewService(c, "111111111", "abc", nil)
 	testCases := []struct {
 		name    string
 		tplId   string
@@ -2,6 +2,16 @@ package sms
 
 import "context"
 
+// TplVerifyCode 验证码短信, tplId 用的都是这种逻辑上的模板 id,
+// 各个服务商在配置里把它映射成自己的模板
+const TplVerifyCode = "verify_code"
+
 type Service interface {
 	Send(ctx context.Context, tplId string, args []string, numbers ...string) error
 }
+
+// ReceiptService 服务商受理之后会给一个回执 id, 之后回调送达状态的时候靠它找到是哪一次发送
+type ReceiptService interface {
+	Service
+	SendWithReceipt(ctx context.Context, tplId string, args []string, numbers ...string) (string, error)
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"time"
+)
+
+type SMSLogService interface {
+	// UpdateDelivery 服务商回调送达状态, 找不到对应的发送记录就忽略
+	UpdateDelivery(ctx context.Context, d domain.SMSDelivery) error
+	// Stats 每天每个服务商的发送量和失败率, start 和 end 所在的那天都包含在内
+	Stats(ctx context.Context, start time.Time, end time.Time) ([]domain.SMSStat, error)
+}
+
+type smsLogService struct {
+	repo repository.SMSLogRepository
+}
+
+func NewSMSLogService(repo repository.SMSLogRepository) SMSLogService {
+	return &smsLogService{
+		repo: repo,
+	}
+}
+
+func (svc *smsLogService) Stats(ctx context.Context, start time.Time, end time.Time) ([]domain.SMSStat, error) {
+	return svc.repo.Stats(ctx, start, end)
+}
+
+func (svc *smsLogService) UpdateDelivery(ctx context.Context, d domain.SMSDelivery) error {
+	return svc.repo.UpdateDelivery(ctx, d)
+}
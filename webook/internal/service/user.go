@@ -6,19 +6,36 @@ import (
 	"context"
 	"errors"
 	"golang.org/x/crypto/bcrypt"
+	"slices"
 )
 
 var ErrUserDuplicateEmail = repository.ErrUserDuplicateEmail
 var ErrInvalidUserOrPassword = errors.New("邮箱或密码不对")
 var ErrUserNotFound = repository.ErrUserNotFound
 var ErrCodeSendTooMany = repository.ErrCodeSendTooMany
+var ErrUserBanned = errors.New("账号已被封禁")
+var ErrInvalidPassword = errors.New("原密码不对")
+var ErrIdentityTaken = errors.New("已经被其它账号绑定")
+var ErrLastLoginMethod = errors.New("不能解绑最后一种登录方式")
 
 type UserService interface {
 	Signup(ctx context.Context, u domain.User) error
 	Login(ctx context.Context, email string, password string) (domain.User, error)
 	Profile(ctx context.Context, id int64) (domain.User, error)
 	FindOrCreate(ctx context.Context, phone string) (domain.User, error)
+	FindOrCreateByEmail(ctx context.Context, email string) (u domain.User, newlyVerified bool, err error)
 	FindOrCreateByWechat(ctx context.Context, wechatInfo domain.WechatInfo) (domain.User, error)
+	UpdateNonSensitiveInfo(ctx context.Context, u domain.User) error
+	Search(ctx context.Context, keyword string, offset int, limit int) ([]domain.User, int64, error)
+	Ban(ctx context.Context, id int64) error
+	Unban(ctx context.Context, id int64) error
+	ChangePassword(ctx context.Context, id int64, oldPassword string, newPassword string) error
+	ResetPasswordByPhone(ctx context.Context, phone string, newPassword string) (domain.User, error)
+	VerifyEmail(ctx context.Context, id int64) error
+	BindPhone(ctx context.Context, id int64, phone string) error
+	BindEmail(ctx context.Context, id int64, email string) error
+	BindWechat(ctx context.Context, id int64, info domain.WechatInfo) error
+	Unbind(ctx context.Context, id int64, method domain.LoginMethod) error
 }
 
 type userService struct {
@@ -50,11 +67,15 @@ func (svc *userService) Login(ctx context.Context, email string, password string
 	if err != nil {
 		return domain.User{}, err
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
+	hash, err := svc.repo.FindPasswordById(ctx, u.Id)
+	if err != nil {
+		return domain.User{}, err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	if err != nil {
 		return domain.User{}, ErrInvalidUserOrPassword
 	}
-	return u, nil
+	return svc.checkStatus(u)
 }
 
 func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, error) {
@@ -62,33 +83,164 @@ func (svc *userService) Profile(ctx context.Context, id int64) (domain.User, err
 }
 
 func (svc *userService) FindOrCreate(ctx context.Context, phone string) (domain.User, error) {
-	u, err := svc.repo.FindByPhone(ctx, phone)
-	if !errors.Is(err, repository.ErrUserNotFound) {
-		return u, err
+	return svc.findOrCreate(ctx, domain.User{Phone: phone}, func() (domain.User, error) {
+		return svc.repo.FindByPhone(ctx, phone)
+	})
+}
+
+// FindOrCreateByEmail 邮箱验证码登录, 能收到验证码说明邮箱是这个人的, 所以新建的用户直接是已验证的
+// 老用户没验证过的, 当初注册的人不一定是邮箱的主人, 可能是别人抢先用这个邮箱设了密码,
+// 所以先把密码清掉再标记成已验证, 这时候 newlyVerified 为 true, 调用方要把这个账号已有的登录都踢掉
+func (svc *userService) FindOrCreateByEmail(ctx context.Context, email string) (u domain.User, newlyVerified bool, err error) {
+	u, err = svc.findOrCreate(ctx, domain.User{Email: email, EmailVerified: true}, func() (domain.User, error) {
+		return svc.repo.FindByEmail(ctx, email)
+	})
+	if err != nil || u.EmailVerified {
+		return u, false, err
 	}
-	// 用户不存在，把用户存进去
-	u = domain.User{
-		Phone: phone,
+	err = svc.repo.UpdatePassword(ctx, u.Id, "")
+	if err != nil {
+		return domain.User{}, false, err
 	}
-	err = svc.repo.Create(ctx, u)
+	err = svc.VerifyEmail(ctx, u.Id)
 	if err != nil {
-		return u, err
+		return domain.User{}, false, err
 	}
-	u, err = svc.repo.FindByPhone(ctx, phone)
-	return u, err
+	u.EmailVerified = true
+	return u, true, nil
 }
 
 func (svc *userService) FindOrCreateByWechat(ctx context.Context, info domain.WechatInfo) (domain.User, error) {
-	u, err := svc.repo.FindByWechat(ctx, info.OpenId)
+	return svc.findOrCreate(ctx, domain.User{WechatInfo: info}, func() (domain.User, error) {
+		return svc.repo.FindByWechat(ctx, info.OpenId)
+	})
+}
+
+// findOrCreate 先用 find 查, 查不到就创建 newUser 再查一遍
+// 两个请求同时登录的时候, 后插入的那个会撞唯一索引, 这时候说明用户已经有了, 直接重新查
+func (svc *userService) findOrCreate(ctx context.Context, newUser domain.User,
+	find func() (domain.User, error)) (domain.User, error) {
+	u, err := find()
+	if err == nil {
+		return svc.checkStatus(u)
+	}
 	if !errors.Is(err, repository.ErrUserNotFound) {
-		return u, err
+		return domain.User{}, err
+	}
+	err = svc.repo.Create(ctx, newUser)
+	if err != nil && !errors.Is(err, repository.ErrUserDuplicate) {
+		return domain.User{}, err
+	}
+	u, err = find()
+	if err != nil {
+		return domain.User{}, err
+	}
+	return svc.checkStatus(u)
+}
+
+// UpdateNonSensitiveInfo 只允许修改昵称、生日、个人简介这类非敏感信息
+func (svc *userService) UpdateNonSensitiveInfo(ctx context.Context, u domain.User) error {
+	return svc.repo.UpdateNonZeroFields(ctx, domain.User{
+		Id:       u.Id,
+		Nickname: u.Nickname,
+		Birthday: u.Birthday,
+		AboutMe:  u.AboutMe,
+	})
+}
+
+func (svc *userService) Search(ctx context.Context, keyword string, offset int, limit int) ([]domain.User, int64, error) {
+	return svc.repo.Search(ctx, keyword, offset, limit)
+}
+
+func (svc *userService) Ban(ctx context.Context, id int64) error {
+	return svc.repo.UpdateStatus(ctx, id, domain.UserStatusBanned)
+}
+
+func (svc *userService) Unban(ctx context.Context, id int64) error {
+	return svc.repo.UpdateStatus(ctx, id, domain.UserStatusActive)
+}
+
+// checkStatus 被封禁的账号不允许登录
+func (svc *userService) checkStatus(u domain.User) (domain.User, error) {
+	if u.Status == domain.UserStatusBanned {
+		return domain.User{}, ErrUserBanned
+	}
+	return u, nil
+}
+
+// ChangePassword 登录状态下改密码, 需要校验原密码
+func (svc *userService) ChangePassword(ctx context.Context, id int64, oldPassword string, newPassword string) error {
+	hash, err := svc.repo.FindPasswordById(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(oldPassword))
+	if err != nil {
+		return ErrInvalidPassword
+	}
+	return svc.updatePassword(ctx, id, newPassword)
+}
+
+// ResetPasswordByPhone 忘记密码, 调用方要先校验过手机验证码
+// 返回用户是为了让调用方吊销这个用户所有的登录
+func (svc *userService) ResetPasswordByPhone(ctx context.Context, phone string, newPassword string) (domain.User, error) {
+	u, err := svc.repo.FindByPhone(ctx, phone)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return u, svc.updatePassword(ctx, u.Id, newPassword)
+}
+
+func (svc *userService) updatePassword(ctx context.Context, id int64, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
 	}
-	// 用户不存在，把用户存进去
-	u = domain.User{}
-	err = svc.repo.Create(ctx, u)
+	return svc.repo.UpdatePassword(ctx, id, string(hash))
+}
+
+// VerifyEmail 调用方要先校验过邮箱验证码
+func (svc *userService) VerifyEmail(ctx context.Context, id int64) error {
+	return svc.repo.UpdateNonZeroFields(ctx, domain.User{
+		Id:            id,
+		EmailVerified: true,
+	})
+}
+
+// BindPhone 已经绑定过手机号的直接换成新的, 调用方要先校验过验证码
+func (svc *userService) BindPhone(ctx context.Context, id int64, phone string) error {
+	return svc.bind(ctx, domain.User{Id: id, Phone: phone})
+}
+
+// BindEmail 通过验证码绑定的邮箱直接是已验证的
+func (svc *userService) BindEmail(ctx context.Context, id int64, email string) error {
+	return svc.bind(ctx, domain.User{Id: id, Email: email, EmailVerified: true})
+}
+
+func (svc *userService) BindWechat(ctx context.Context, id int64, info domain.WechatInfo) error {
+	return svc.bind(ctx, domain.User{Id: id, WechatInfo: info})
+}
+
+func (svc *userService) bind(ctx context.Context, u domain.User) error {
+	err := svc.repo.UpdateNonZeroFields(ctx, u)
+	if errors.Is(err, repository.ErrUserDuplicate) {
+		return ErrIdentityTaken
+	}
+	return err
+}
+
+// Unbind 至少要留一种登录方式, 不然这个账号就再也登录不上了
+func (svc *userService) Unbind(ctx context.Context, id int64, method domain.LoginMethod) error {
+	u, err := svc.repo.FindById(ctx, id)
 	if err != nil {
-		return u, err
+		return err
+	}
+	methods := u.LoginMethods()
+	if !slices.Contains(methods, method) {
+		return nil
+	}
+	if len(methods) == 1 {
+		return ErrLastLoginMethod
 	}
-	u, err = svc.repo.FindByWechat(ctx, info.OpenId)
-	return u, err
+	return svc.repo.ClearLoginMethod(ctx, id, method)
 }
@@ -0,0 +1,419 @@
+package service
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/repository"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+)
+
+// fakeUserRepository 内存里的用户表, 邮箱、手机号、openid 都是唯一的
+// 没用到的方法走嵌入的 nil 接口, 调到了直接 panic
+type fakeUserRepository struct {
+	repository.UserRepository
+	users []domain.User
+	// passwords id -> 密码的 hash, 和真的实现一样不放在 domain.User 里
+	passwords map[int64]string
+	// beforeCreate 模拟 Create 之前别的请求已经把用户插进去了
+	beforeCreate func(r *fakeUserRepository)
+	createErr    error
+}
+
+func (r *fakeUserRepository) Create(ctx context.Context, u domain.User) error {
+	if r.beforeCreate != nil {
+		r.beforeCreate(r)
+	}
+	if r.createErr != nil {
+		return r.createErr
+	}
+	return r.insert(u)
+}
+
+func (r *fakeUserRepository) insert(u domain.User) error {
+	for _, old := range r.users {
+		if (u.Email != "" && u.Email == old.Email) ||
+			(u.Phone != "" && u.Phone == old.Phone) ||
+			(u.WechatInfo.OpenId != "" && u.WechatInfo.OpenId == old.WechatInfo.OpenId) {
+			return repository.ErrUserDuplicate
+		}
+	}
+	u.Id = int64(len(r.users) + 1)
+	r.users = append(r.users, u)
+	return nil
+}
+
+func (r *fakeUserRepository) find(match func(u domain.User) bool) (domain.User, error) {
+	for _, u := range r.users {
+		if match(u) {
+			return u, nil
+		}
+	}
+	return domain.User{}, repository.ErrUserNotFound
+}
+
+func (r *fakeUserRepository) FindByEmail(ctx context.Context, email string) (domain.User, error) {
+	return r.find(func(u domain.User) bool {
+		return u.Email == email
+	})
+}
+
+func (r *fakeUserRepository) FindPasswordById(ctx context.Context, id int64) (string, error) {
+	hash, ok := r.passwords[id]
+	if !ok {
+		return "", repository.ErrUserNotFound
+	}
+	return hash, nil
+}
+
+func (r *fakeUserRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	r.passwords[id] = hash
+	return nil
+}
+
+// UpdateNonZeroFields 测试里只用到了邮箱、手机号、微信和邮箱已验证
+func (r *fakeUserRepository) UpdateNonZeroFields(ctx context.Context, u domain.User) error {
+	for _, old := range r.users {
+		if old.Id != u.Id && ((u.Email != "" && u.Email == old.Email) ||
+			(u.Phone != "" && u.Phone == old.Phone) ||
+			(u.WechatInfo.OpenId != "" && u.WechatInfo.OpenId == old.WechatInfo.OpenId)) {
+			return repository.ErrUserDuplicate
+		}
+	}
+	for i := range r.users {
+		if r.users[i].Id != u.Id {
+			continue
+		}
+		if u.Email != "" {
+			r.users[i].Email = u.Email
+		}
+		if u.Phone != "" {
+			r.users[i].Phone = u.Phone
+		}
+		if u.WechatInfo.OpenId != "" {
+			r.users[i].WechatInfo = u.WechatInfo
+		}
+		if u.EmailVerified {
+			r.users[i].EmailVerified = true
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) FindByPhone(ctx context.Context, phone string) (domain.User, error) {
+	return r.find(func(u domain.User) bool {
+		return u.Phone == phone
+	})
+}
+
+func (r *fakeUserRepository) FindByWechat(ctx context.Context, openId string) (domain.User, error) {
+	return r.find(func(u domain.User) bool {
+		return u.WechatInfo.OpenId == openId
+	})
+}
+
+func TestUserService_FindOrCreate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		repo     *fakeUserRepository
+		phone    string
+		wantUser domain.User
+		wantErr  error
+		wantCnt  int
+	}{
+		{
+			name:     "新用户",
+			repo:     &fakeUserRepository{},
+			phone:    "13800000000",
+			wantUser: domain.User{Id: 1, Phone: "13800000000"},
+			wantCnt:  1,
+		},
+		{
+			name: "老用户",
+			repo: &fakeUserRepository{
+				users: []domain.User{{Id: 1, Phone: "13800000000", Nickname: "老用户"}},
+			},
+			phone:    "13800000000",
+			wantUser: domain.User{Id: 1, Phone: "13800000000", Nickname: "老用户"},
+			wantCnt:  1,
+		},
+		{
+			name: "并发创建, 插入冲突之后重新查",
+			repo: &fakeUserRepository{
+				beforeCreate: func(r *fakeUserRepository) {
+					_ = r.insert(domain.User{Phone: "13800000000", Nickname: "另一个请求"})
+				},
+			},
+			phone:    "13800000000",
+			wantUser: domain.User{Id: 1, Phone: "13800000000", Nickname: "另一个请求"},
+			wantCnt:  1,
+		},
+		{
+			name: "被封禁",
+			repo: &fakeUserRepository{
+				users: []domain.User{{Id: 1, Phone: "13800000000", Status: domain.UserStatusBanned}},
+			},
+			phone:   "13800000000",
+			wantErr: ErrUserBanned,
+			wantCnt: 1,
+		},
+		{
+			name:    "创建失败",
+			repo:    &fakeUserRepository{createErr: errors.New("db error")},
+			phone:   "13800000000",
+			wantErr: errors.New("db error"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewUserService(tc.repo)
+			u, err := svc.FindOrCreate(context.Background(), tc.phone)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantUser, u)
+			assert.Len(t, tc.repo.users, tc.wantCnt)
+		})
+	}
+}
+
+func TestUserService_FindOrCreateByWechat(t *testing.T) {
+	info := domain.WechatInfo{OpenId: "openid", UnionId: "unionid"}
+	testCases := []struct {
+		name     string
+		repo     *fakeUserRepository
+		wantUser domain.User
+		wantErr  error
+		wantCnt  int
+	}{
+		{
+			name:     "新用户, 保存 openid 和 unionid",
+			repo:     &fakeUserRepository{},
+			wantUser: domain.User{Id: 1, WechatInfo: info},
+			wantCnt:  1,
+		},
+		{
+			name: "老用户",
+			repo: &fakeUserRepository{
+				users: []domain.User{{Id: 1, Nickname: "老用户", WechatInfo: info}},
+			},
+			wantUser: domain.User{Id: 1, Nickname: "老用户", WechatInfo: info},
+			wantCnt:  1,
+		},
+		{
+			name: "并发创建, 插入冲突之后重新查",
+			repo: &fakeUserRepository{
+				beforeCreate: func(r *fakeUserRepository) {
+					_ = r.insert(domain.User{Nickname: "另一个请求", WechatInfo: info})
+				},
+			},
+			wantUser: domain.User{Id: 1, Nickname: "另一个请求", WechatInfo: info},
+			wantCnt:  1,
+		},
+		{
+			name: "被封禁",
+			repo: &fakeUserRepository{
+				users: []domain.User{{Id: 1, WechatInfo: info, Status: domain.UserStatusBanned}},
+			},
+			wantErr: ErrUserBanned,
+			wantCnt: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := NewUserService(tc.repo)
+			u, err := svc.FindOrCreateByWechat(context.Background(), info)
+			if tc.wantErr != nil {
+				assert.Equal(t, tc.wantErr, err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantUser, u)
+			assert.Len(t, tc.repo.users, tc.wantCnt)
+		})
+	}
+}
+
+func hashPassword(t *testing.T, password string) string {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+	return string(hash)
+}
+
+func TestUserService_ChangePassword(t *testing.T) {
+	testCases := []struct {
+		name        string
+		repo        func(t *testing.T) *fakeUserRepository
+		oldPassword string
+		wantErr     error
+		// wantPassword 改完之后能通过校验的密码
+		wantPassword string
+	}{
+		{
+			name: "修改成功",
+			repo: func(t *testing.T) *fakeUserRepository {
+				return &fakeUserRepository{
+					users:     []domain.User{{Id: 1}},
+					passwords: map[int64]string{1: hashPassword(t, "hello#world123")},
+				}
+			},
+			oldPassword:  "hello#world123",
+			wantPassword: "hello#world456",
+		},
+		{
+			name: "原密码不对",
+			repo: func(t *testing.T) *fakeUserRepository {
+				return &fakeUserRepository{
+					users:     []domain.User{{Id: 1}},
+					passwords: map[int64]string{1: hashPassword(t, "hello#world123")},
+				}
+			},
+			oldPassword:  "hello#world000",
+			wantErr:      ErrInvalidPassword,
+			wantPassword: "hello#world123",
+		},
+		{
+			name: "用户不存在",
+			repo: func(t *testing.T) *fakeUserRepository {
+				return &fakeUserRepository{passwords: map[int64]string{}}
+			},
+			oldPassword: "hello#world123",
+			wantErr:     repository.ErrUserNotFound,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := tc.repo(t)
+			svc := NewUserService(repo)
+			err := svc.ChangePassword(context.Background(), 1, tc.oldPassword, "hello#world456")
+			assert.Equal(t, tc.wantErr, err)
+			if tc.wantPassword == "" {
+				return
+			}
+			err = bcrypt.CompareHashAndPassword([]byte(repo.passwords[1]), []byte(tc.wantPassword))
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestUserService_Bind(t *testing.T) {
+	testCases := []struct {
+		name    string
+		bind    func(svc UserService) error
+		wantErr error
+	}{
+		{
+			name: "绑定手机号",
+			bind: func(svc UserService) error {
+				return svc.BindPhone(context.Background(), 1, "13800000001")
+			},
+		},
+		{
+			name: "手机号已经被别人绑定了",
+			bind: func(svc UserService) error {
+				return svc.BindPhone(context.Background(), 1, "13800000002")
+			},
+			wantErr: ErrIdentityTaken,
+		},
+		{
+			name: "邮箱已经被别人绑定了",
+			bind: func(svc UserService) error {
+				return svc.BindEmail(context.Background(), 1, "456@qq.com")
+			},
+			wantErr: ErrIdentityTaken,
+		},
+		{
+			name: "微信已经被别人绑定了",
+			bind: func(svc UserService) error {
+				return svc.BindWechat(context.Background(), 1, domain.WechatInfo{OpenId: "openid-2"})
+			},
+			wantErr: ErrIdentityTaken,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := &fakeUserRepository{
+				users: []domain.User{
+					{Id: 1, Email: "123@qq.com"},
+					{Id: 2, Email: "456@qq.com", Phone: "13800000002", WechatInfo: domain.WechatInfo{OpenId: "openid-2"}},
+				},
+			}
+			err := tc.bind(NewUserService(repo))
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestUserService_Login(t *testing.T) {
+	repo := &fakeUserRepository{
+		users:     []domain.User{{Id: 1, Email: "123@qq.com"}},
+		passwords: map[int64]string{1: hashPassword(t, "hello#world123")},
+	}
+	svc := NewUserService(repo)
+	u, err := svc.Login(context.Background(), "123@qq.com", "hello#world123")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), u.Id)
+	_, err = svc.Login(context.Background(), "123@qq.com", "hello#world000")
+	assert.Equal(t, ErrInvalidUserOrPassword, err)
+	_, err = svc.Login(context.Background(), "456@qq.com", "hello#world123")
+	assert.Equal(t, ErrInvalidUserOrPassword, err)
+}
+
+func TestUserService_FindOrCreateByEmail(t *testing.T) {
+	testCases := []struct {
+		name string
+		repo func(t *testing.T) *fakeUserRepository
+		// wantNewlyVerified 为 true 的时候原来的密码要失效
+		wantNewlyVerified bool
+		wantLoginErr      error
+	}{
+		{
+			name: "别人抢先用这个邮箱设了密码",
+			repo: func(t *testing.T) *fakeUserRepository {
+				return &fakeUserRepository{
+					users:     []domain.User{{Id: 1, Email: "123@qq.com"}},
+					passwords: map[int64]string{1: hashPassword(t, "hello#world123")},
+				}
+			},
+			wantNewlyVerified: true,
+			wantLoginErr:      ErrInvalidUserOrPassword,
+		},
+		{
+			name: "已经验证过的不动密码",
+			repo: func(t *testing.T) *fakeUserRepository {
+				return &fakeUserRepository{
+					users:     []domain.User{{Id: 1, Email: "123@qq.com", EmailVerified: true}},
+					passwords: map[int64]string{1: hashPassword(t, "hello#world123")},
+				}
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := tc.repo(t)
+			svc := NewUserService(repo)
+			u, newlyVerified, err := svc.FindOrCreateByEmail(context.Background(), "123@qq.com")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), u.Id)
+			assert.True(t, u.EmailVerified)
+			assert.True(t, repo.users[0].EmailVerified)
+			assert.Equal(t, tc.wantNewlyVerified, newlyVerified)
+			_, err = svc.Login(context.Background(), "123@qq.com", "hello#world123")
+			assert.Equal(t, tc.wantLoginErr, err)
+		})
+	}
+}
+
+func TestUserService_FindOrCreateByEmail_Create(t *testing.T) {
+	svc := NewUserService(&fakeUserRepository{passwords: map[int64]string{}})
+	u, newlyVerified, err := svc.FindOrCreateByEmail(context.Background(), "123@qq.com")
+	require.NoError(t, err)
+	// 新建的用户本来就没有别人设的密码
+	assert.False(t, newlyVerified)
+	assert.True(t, u.EmailVerified)
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"time"
+)
+
+const (
+	// adminSMSStatsDays 不传时间范围的时候默认查最近几天
+	adminSMSStatsDays = 7
+	// adminSMSStatsDaysMax 一次最多查多少天
+	adminSMSStatsDaysMax = 92
+)
+
+// AdminSMSHandler 后台查看短信发送情况, 用户说收不到验证码的时候排查用
+type AdminSMSHandler struct {
+	svc service.SMSLogService
+}
+
+func NewAdminSMSHandler(svc service.SMSLogService) *AdminSMSHandler {
+	return &AdminSMSHandler{
+		svc: svc,
+	}
+}
+
+func (h *AdminSMSHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/admin/sms")
+	g.GET("/stats", middleware.RequirePermission(domain.PermSMSRead), h.Stats)
+}
+
+// Stats start 和 end 格式是 2006-01-02, 都包含在内, 默认是最近 7 天
+func (h *AdminSMSHandler) Stats(ctx *gin.Context) {
+	end := time.Now()
+	start := end.AddDate(0, 0, 1-adminSMSStatsDays)
+	var err error
+	if s := ctx.Query("start"); s != "" {
+		start, err = time.ParseInLocation(time.DateOnly, s, time.Local)
+	}
+	if e := ctx.Query("end"); e != "" && err == nil {
+		end, err = time.ParseInLocation(time.DateOnly, e, time.Local)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "日期格式不对",
+		})
+		return
+	}
+	if end.Before(start) || end.Sub(start) >= time.Hour*24*adminSMSStatsDaysMax {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "日期范围不对",
+		})
+		return
+	}
+	stats, err := h.svc.Stats(ctx, start, end)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	type StatVo struct {
+		Day         string  `json:"day"`
+		Provider    string  `json:"provider"`
+		Total       int64   `json:"total"`
+		Failed      int64   `json:"failed"`
+		FailureRate float64 `json:"failureRate"`
+		// Delivered 和 Undelivered 是服务商回调了送达状态的
+		Delivered   int64 `json:"delivered"`
+		Undelivered int64 `json:"undelivered"`
+		// AvgLatency 毫秒
+		AvgLatency int64 `json:"avgLatency"`
+	}
+	res := make([]StatVo, 0, len(stats))
+	for _, s := range stats {
+		res = append(res, StatVo{
+			Day:         s.Day,
+			Provider:    s.Provider,
+			Total:       s.Total,
+			Failed:      s.Failed,
+			FailureRate: s.FailureRate(),
+			Delivered:   s.Delivered,
+			Undelivered: s.Undelivered,
+			AvgLatency:  s.AvgLatency.Milliseconds(),
+		})
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+		Data:    res,
+	})
+}
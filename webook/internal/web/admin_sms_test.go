@@ -0,0 +1,128 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	svcmocks "basic_go/webook/internal/service/mocks"
+	ijwt "basic_go/webook/internal/web/jwt"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminSMSHandler_Stats(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.SMSLogService
+		roles    []string
+		url      string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "按天按服务商统计",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().Stats(gomock.Any(),
+					time.Date(2026, 10, 1, 0, 0, 0, 0, time.Local),
+					time.Date(2026, 10, 2, 0, 0, 0, 0, time.Local)).
+					Return([]domain.SMSStat{
+						{Day: "2026-10-01", Provider: "tencent", Total: 4, Failed: 1, Delivered: 2, Undelivered: 1,
+							AvgLatency: time.Millisecond * 120},
+					}, nil)
+				return svc
+			},
+			roles:    []string{domain.RoleOperator},
+			url:      "/admin/sms/stats?start=2026-10-01&end=2026-10-02",
+			wantCode: http.StatusOK,
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data: []any{
+					map[string]any{
+						"day":         "2026-10-01",
+						"provider":    "tencent",
+						"total":       float64(4),
+						"failed":      float64(1),
+						"failureRate": 0.25,
+						"delivered":   float64(2),
+						"undelivered": float64(1),
+						"avgLatency":  float64(120),
+					},
+				},
+			},
+		},
+		{
+			name: "日期格式不对",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			roles:    []string{domain.RoleAdmin},
+			url:      "/admin/sms/stats?start=2026/10/01",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "日期格式不对"},
+		},
+		{
+			name: "结束早于开始",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			roles:    []string{domain.RoleAdmin},
+			url:      "/admin/sms/stats?start=2026-10-02&end=2026-10-01",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "日期范围不对"},
+		},
+		{
+			name: "查询失败",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().Stats(gomock.Any(), gomock.Any(), gomock.Any()).Return(nil, errors.New("db error"))
+				return svc
+			},
+			roles:    []string{domain.RoleAdmin},
+			url:      "/admin/sms/stats",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+		{
+			name: "普通用户不能看",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			url:      "/admin/sms/stats",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 1, Roles: tc.roles})
+			})
+			NewAdminSMSHandler(tc.mock(ctrl)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodGet, tc.url, nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
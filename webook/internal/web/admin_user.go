@@ -0,0 +1,152 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	ijwt "basic_go/webook/internal/web/jwt"
+	"basic_go/webook/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// adminPageSizeDefault 不传或者传错了 size 的时候一页多少条
+	adminPageSizeDefault = 20
+	adminPageSizeMax     = 100
+)
+
+// AdminUserHandler 后台的用户管理, 查用户、封号、强制下线
+type AdminUserHandler struct {
+	svc service.UserService
+	ijwt.Handler
+}
+
+func NewAdminUserHandler(svc service.UserService, hdl ijwt.Handler) *AdminUserHandler {
+	return &AdminUserHandler{
+		svc:     svc,
+		Handler: hdl,
+	}
+}
+
+func (h *AdminUserHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/admin/users")
+	g.GET("", middleware.RequirePermission(domain.PermUserRead), h.Search)
+	g.POST("/:id/ban", middleware.RequirePermission(domain.PermUserBan), h.Ban)
+	g.POST("/:id/unban", middleware.RequirePermission(domain.PermUserBan), h.Unban)
+	g.POST("/:id/logout", middleware.RequirePermission(domain.PermUserBan), h.ForceLogout)
+}
+
+// Search 按照邮箱、手机号、昵称搜索, 分页参数 page 从 1 开始
+func (h *AdminUserHandler) Search(ctx *gin.Context) {
+	page, _ := strconv.Atoi(ctx.DefaultQuery("page", "1"))
+	size, _ := strconv.Atoi(ctx.Query("size"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = adminPageSizeDefault
+	}
+	if size > adminPageSizeMax {
+		size = adminPageSizeMax
+	}
+	keyword := strings.TrimSpace(ctx.Query("keyword"))
+	users, total, err := h.svc.Search(ctx, keyword, (page-1)*size, size)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	type UserVo struct {
+		Id       int64    `json:"id"`
+		Email    string   `json:"email"`
+		Phone    string   `json:"phone"`
+		Nickname string   `json:"nickname"`
+		Roles    []string `json:"roles"`
+		Banned   bool     `json:"banned"`
+		Ctime    int64    `json:"ctime"`
+	}
+	type Page struct {
+		Total int64    `json:"total"`
+		Users []UserVo `json:"users"`
+	}
+	res := Page{
+		Total: total,
+		Users: make([]UserVo, 0, len(users)),
+	}
+	for _, u := range users {
+		res.Users = append(res.Users, UserVo{
+			Id:       u.Id,
+			Email:    u.Email,
+			Phone:    u.Phone,
+			Nickname: u.Nickname,
+			Roles:    u.Roles,
+			Banned:   u.Status == domain.UserStatusBanned,
+			Ctime:    u.Ctime.UnixMilli(),
+		})
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+		Data:    res,
+	})
+}
+
+// Ban 封号之后顺便踢掉所有登录
+func (h *AdminUserHandler) Ban(ctx *gin.Context) {
+	id, ok := h.userId(ctx)
+	if !ok {
+		return
+	}
+	err := h.svc.Ban(ctx, id)
+	if err == nil {
+		err = h.RevokeOtherSessions(ctx, id, "")
+	}
+	h.result(ctx, err)
+}
+
+func (h *AdminUserHandler) Unban(ctx *gin.Context) {
+	id, ok := h.userId(ctx)
+	if !ok {
+		return
+	}
+	h.result(ctx, h.svc.Unban(ctx, id))
+}
+
+// ForceLogout 吊销这个用户所有的 ssid
+func (h *AdminUserHandler) ForceLogout(ctx *gin.Context) {
+	id, ok := h.userId(ctx)
+	if !ok {
+		return
+	}
+	h.result(ctx, h.RevokeOtherSessions(ctx, id, ""))
+}
+
+func (h *AdminUserHandler) userId(ctx *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "用户 id 不对",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+func (h *AdminUserHandler) result(ctx *gin.Context, err error) {
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+	})
+}
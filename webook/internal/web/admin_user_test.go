@@ -0,0 +1,187 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	svcmocks "basic_go/webook/internal/service/mocks"
+	ijwt "basic_go/webook/internal/web/jwt"
+	jwtmocks "basic_go/webook/internal/web/jwt/mocks"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdminUserHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler)
+		roles    []string
+		method   string
+		url      string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "搜索用户",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Search(gomock.Any(), "138", 20, 20).
+					Return([]domain.User{
+						{Id: 1, Phone: "13800000000", Status: domain.UserStatusBanned, Ctime: time.UnixMilli(100)},
+					}, int64(21), nil)
+				return usersvc, jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleOperator},
+			method:   http.MethodGet,
+			url:      "/admin/users?keyword=138&page=2&size=20",
+			wantCode: http.StatusOK,
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data: map[string]any{
+					"total": float64(21),
+					"users": []any{
+						map[string]any{
+							"id":       float64(1),
+							"email":    "",
+							"phone":    "13800000000",
+							"nickname": "",
+							"roles":    nil,
+							"banned":   true,
+							"ctime":    float64(100),
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "size 不对用默认值",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Search(gomock.Any(), "", 0, 20).Return(nil, int64(0), nil)
+				return usersvc, jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleOperator},
+			method:   http.MethodGet,
+			url:      "/admin/users?size=0",
+			wantCode: http.StatusOK,
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data:    map[string]any{"total": float64(0), "users": []any{}},
+			},
+		},
+		{
+			name: "size 太大",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Search(gomock.Any(), "", 100, 100).Return(nil, int64(0), nil)
+				return usersvc, jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleOperator},
+			method:   http.MethodGet,
+			url:      "/admin/users?page=2&size=1000",
+			wantCode: http.StatusOK,
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data:    map[string]any{"total": float64(0), "users": []any{}},
+			},
+		},
+		{
+			name: "封号并踢下线",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Ban(gomock.Any(), int64(123)).Return(nil)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().RevokeOtherSessions(gomock.Any(), int64(123), "").Return(nil)
+				return usersvc, hdl
+			},
+			roles:    []string{domain.RoleAdmin},
+			method:   http.MethodPost,
+			url:      "/admin/users/123/ban",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 0, Message: "ok"},
+		},
+		{
+			name: "封号失败",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Ban(gomock.Any(), int64(123)).Return(errors.New("db error"))
+				return usersvc, jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleAdmin},
+			method:   http.MethodPost,
+			url:      "/admin/users/123/ban",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+		{
+			name: "运营不能封号",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				return svcmocks.NewMockUserService(ctrl), jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleOperator},
+			method:   http.MethodPost,
+			url:      "/admin/users/123/ban",
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "强制下线",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().RevokeOtherSessions(gomock.Any(), int64(123), "").Return(nil)
+				return svcmocks.NewMockUserService(ctrl), hdl
+			},
+			roles:    []string{domain.RoleAdmin},
+			method:   http.MethodPost,
+			url:      "/admin/users/123/logout",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 0, Message: "ok"},
+		},
+		{
+			name: "id 不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				return svcmocks.NewMockUserService(ctrl), jwtmocks.NewMockHandler(ctrl)
+			},
+			roles:    []string{domain.RoleAdmin},
+			method:   http.MethodPost,
+			url:      "/admin/users/abc/unban",
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "用户 id 不对"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 1, Roles: tc.roles})
+			})
+			h := NewAdminUserHandler(tc.mock(ctrl))
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.url, nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantCode != http.StatusOK {
+				return
+			}
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/web/middleware"
+	"basic_go/webook/pkg/clientip"
+	"encoding/base64"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// captchaTokenHeader 前端答对图形验证码之后, 发短信验证码的时候用这个头带上通行 token
+const captchaTokenHeader = "X-Captcha-Token"
+
+type CaptchaHandler struct {
+	svc service.CaptchaService
+}
+
+func NewCaptchaHandler(svc service.CaptchaService) *CaptchaHandler {
+	return &CaptchaHandler{
+		svc: svc,
+	}
+}
+
+func (h *CaptchaHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/captcha")
+	g.GET("", middleware.Public, h.Generate)
+	g.POST("/verify", middleware.Public, h.Verify)
+}
+
+// Generate kind 是 digit 或者 math, 默认 digit
+func (h *CaptchaHandler) Generate(ctx *gin.Context) {
+	kind := domain.CaptchaKind(ctx.DefaultQuery("kind", string(domain.CaptchaDigit)))
+	if kind != domain.CaptchaDigit && kind != domain.CaptchaMath {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "图形验证码类型不对",
+		})
+		return
+	}
+	c, err := h.svc.Generate(ctx, kind)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+		Data: gin.H{
+			"id":    c.Id,
+			"image": "data:image/png;base64," + base64.StdEncoding.EncodeToString(c.Image),
+		},
+	})
+}
+
+func (h *CaptchaHandler) Verify(ctx *gin.Context) {
+	type Req struct {
+		Id     string `json:"id"`
+		Answer string `json:"answer"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if req.Id == "" || req.Answer == "" {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "图形验证码为空",
+		})
+		return
+	}
+	token, err := h.svc.Verify(clientip.WithIP(ctx, ctx.ClientIP()), req.Id, req.Answer)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "ok",
+			Data: gin.H{
+				"token": token,
+			},
+		})
+	case errors.Is(err, service.ErrCaptchaInvalid):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    11,
+			Message: "图形验证码不对, 请重新获取",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	svcmocks "basic_go/webook/internal/service/mocks"
+	"basic_go/webook/pkg/clientip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCaptchaHandler(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.CaptchaService
+		method   string
+		url      string
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "生成算式验证码",
+			mock: func(ctrl *gomock.Controller) service.CaptchaService {
+				svc := svcmocks.NewMockCaptchaService(ctrl)
+				svc.EXPECT().Generate(gomock.Any(), domain.CaptchaMath).
+					Return(domain.Captcha{Id: "abc", Kind: domain.CaptchaMath, Image: []byte("png")}, nil)
+				return svc
+			},
+			method: http.MethodGet,
+			url:    "/captcha?kind=math",
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data: map[string]any{
+					"id":    "abc",
+					"image": "data:image/png;base64,cG5n",
+				},
+			},
+		},
+		{
+			name: "类型不对",
+			mock: func(ctrl *gomock.Controller) service.CaptchaService {
+				return svcmocks.NewMockCaptchaService(ctrl)
+			},
+			method:   http.MethodGet,
+			url:      "/captcha?kind=audio",
+			wantBody: Result{Code: 4, Message: "图形验证码类型不对"},
+		},
+		{
+			name: "答对了",
+			mock: func(ctrl *gomock.Controller) service.CaptchaService {
+				svc := svcmocks.NewMockCaptchaService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), "abc", "7").
+					DoAndReturn(func(ctx context.Context, id string, answer string) (string, error) {
+						// 通行 token 绑定答题的 IP
+						assert.Equal(t, "10.0.0.1", clientip.FromContext(ctx))
+						return "token", nil
+					})
+				return svc
+			},
+			method:  http.MethodPost,
+			url:     "/captcha/verify",
+			reqBody: `{"id": "abc", "answer": "7"}`,
+			wantBody: Result{
+				Code:    0,
+				Message: "ok",
+				Data:    map[string]any{"token": "token"},
+			},
+		},
+		{
+			name: "答错了",
+			mock: func(ctrl *gomock.Controller) service.CaptchaService {
+				svc := svcmocks.NewMockCaptchaService(ctrl)
+				svc.EXPECT().Verify(gomock.Any(), "abc", "8").Return("", service.ErrCaptchaInvalid)
+				return svc
+			},
+			method:   http.MethodPost,
+			url:      "/captcha/verify",
+			reqBody:  `{"id": "abc", "answer": "8"}`,
+			wantBody: Result{Code: 11, Message: "图形验证码不对, 请重新获取"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			NewCaptchaHandler(tc.mock(ctrl)).RegisterRoutes(server)
+
+			req, err := http.NewRequest(tc.method, tc.url, bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "10.0.0.1:12345"
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
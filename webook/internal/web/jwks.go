@@ -0,0 +1,29 @@
+package web
+
+import (
+	ijwt "basic_go/webook/internal/web/jwt"
+	"basic_go/webook/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// JWKSHandler 发布短 token 的公钥, 其它服务拿去就能离线校验 UserClaims
+type JWKSHandler struct {
+	keys *ijwt.KeyRing
+}
+
+func NewJWKSHandler(keys *ijwt.KeyRing) *JWKSHandler {
+	return &JWKSHandler{
+		keys: keys,
+	}
+}
+
+func (h *JWKSHandler) RegisterRoutes(server *gin.Engine) {
+	server.GET("/.well-known/jwks.json", middleware.Public, h.JWKS)
+}
+
+// JWKS 按照标准格式直接返回, 不套 Result
+func (h *JWKSHandler) JWKS(ctx *gin.Context) {
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"sort"
+)
+
+// JWK RFC 7517 里的公钥格式, 只放校验需要的字段
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 导出所有非对称 key 的公钥, HMAC 的 key 是共享密钥, 绝对不能发布出去
+func (k *KeyRing) JWKS() JWKSet {
+	res := JWKSet{Keys: make([]JWK, 0, len(k.keys))}
+	for _, key := range k.keys {
+		jwk, ok := toJWK(key)
+		if ok {
+			res.Keys = append(res.Keys, jwk)
+		}
+	}
+	// map 遍历顺序不固定, 排个序让输出稳定, 方便缓存
+	sort.Slice(res.Keys, func(i, j int) bool {
+		return res.Keys[i].Kid < res.Keys[j].Kid
+	})
+	return res
+}
+
+func toJWK(key Key) (JWK, bool) {
+	enc := base64.RawURLEncoding
+	switch pub := key.VerifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: key.Kid,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			N:   enc.EncodeToString(pub.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: key.Kid,
+			Use: "sig",
+			Alg: key.Method.Alg(),
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(pub),
+		}, true
+	default:
+		return JWK{}, false
+	}
+}
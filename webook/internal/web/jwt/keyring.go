@@ -0,0 +1,157 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKid = errors.New("未知的 kid")
+
+// Key 一把签名密钥, Kid 会写进 token 的 header 里
+type Key struct {
+	Kid    string
+	Method jwt.SigningMethod
+	// SignKey 签名用, VerifyKey 校验用, HMAC 两个是同一个
+	SignKey   interface{}
+	VerifyKey interface{}
+}
+
+func NewHMACKey(kid string, secret []byte) Key {
+	return Key{
+		Kid:       kid,
+		Method:    jwt.SigningMethodHS512,
+		SignKey:   secret,
+		VerifyKey: secret,
+	}
+}
+
+// NewRSAKey RS256 签名, 公钥可以通过 JWKS 发布出去, 其它服务不需要知道私钥就能校验
+func NewRSAKey(kid string, priv *rsa.PrivateKey) Key {
+	return Key{
+		Kid:       kid,
+		Method:    jwt.SigningMethodRS256,
+		SignKey:   priv,
+		VerifyKey: &priv.PublicKey,
+	}
+}
+
+func NewEd25519Key(kid string, priv ed25519.PrivateKey) Key {
+	return Key{
+		Kid:       kid,
+		Method:    jwt.SigningMethodEdDSA,
+		SignKey:   priv,
+		VerifyKey: priv.Public(),
+	}
+}
+
+// ParsePrivateKeyPEM 根据 alg 解析 PEM 格式的私钥, 支持 RS256 和 EdDSA
+func ParsePrivateKeyPEM(kid string, alg string, pemStr string) (Key, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		priv, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(pemStr))
+		if err != nil {
+			return Key{}, fmt.Errorf("kid %s: %w", kid, err)
+		}
+		return NewRSAKey(kid, priv), nil
+	case jwt.SigningMethodEdDSA.Alg():
+		priv, err := jwt.ParseEdPrivateKeyFromPEM([]byte(pemStr))
+		if err != nil {
+			return Key{}, fmt.Errorf("kid %s: %w", kid, err)
+		}
+		edPriv, ok := priv.(ed25519.PrivateKey)
+		if !ok {
+			return Key{}, fmt.Errorf("kid %s 不是 ed25519 私钥", kid)
+		}
+		return NewEd25519Key(kid, edPriv), nil
+	default:
+		return Key{}, fmt.Errorf("不支持的签名算法 %s", alg)
+	}
+}
+
+// KeyRing 一组密钥, 只用 active 的那把签名, 但是所有的都能用来校验
+// 轮换密钥的时候先加新 key 并切成 active, 等旧 token 都过期了再把旧 key 删掉
+type KeyRing struct {
+	active Key
+	keys   map[string]Key
+}
+
+func NewKeyRing(activeKid string, keys ...Key) (*KeyRing, error) {
+	ring := &KeyRing{
+		keys: make(map[string]Key, len(keys)),
+	}
+	for _, k := range keys {
+		if _, ok := ring.keys[k.Kid]; ok {
+			return nil, fmt.Errorf("kid %s 重复", k.Kid)
+		}
+		ring.keys[k.Kid] = k
+	}
+	active, ok := ring.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("%w: active kid %s", ErrUnknownKid, activeKid)
+	}
+	ring.active = active
+	return ring, nil
+}
+
+// NewHMACKeyRing 从配置里的 kid -> secret 构造 KeyRing
+// secret 为空一般是环境变量没有配置, 直接报错, 不然谁都能伪造 token
+func NewHMACKeyRing(activeKid string, secrets map[string]string) (*KeyRing, error) {
+	keys := make([]Key, 0, len(secrets))
+	for kid, secret := range secrets {
+		if secret == "" {
+			return nil, fmt.Errorf("kid %s 的密钥为空", kid)
+		}
+		keys = append(keys, NewHMACKey(kid, []byte(secret)))
+	}
+	return NewKeyRing(activeKid, keys...)
+}
+
+// NewPEMKeyRing 从配置里的 kid -> PEM 私钥构造非对称的 KeyRing
+func NewPEMKeyRing(activeKid string, alg string, pems map[string]string) (*KeyRing, error) {
+	keys := make([]Key, 0, len(pems))
+	for kid, pemStr := range pems {
+		key, err := ParsePrivateKeyPEM(kid, alg, pemStr)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeyRing(activeKid, keys...)
+}
+
+func (k *KeyRing) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.Method, claims)
+	token.Header["kid"] = k.active.Kid
+	return token.SignedString(k.active.SignKey)
+}
+
+// Parse 有 kid 就用对应的 key 校验, 没有 kid 的老 token 把所有 key 都试一遍
+func (k *KeyRing) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, k.keyFunc)
+}
+
+func (k *KeyRing) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		set := jwt.VerificationKeySet{}
+		for _, key := range k.keys {
+			if key.Method.Alg() == token.Method.Alg() {
+				set.Keys = append(set.Keys, key.VerifyKey)
+			}
+		}
+		return set, nil
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownKid, kid)
+	}
+	// 防止拿 kid 对应的 key 去校验别的算法签出来的 token
+	if key.Method.Alg() != token.Method.Alg() {
+		return nil, fmt.Errorf("kid %s 的算法是 %s, token 用的是 %s",
+			kid, key.Method.Alg(), token.Method.Alg())
+	}
+	return key.VerifyKey, nil
+}
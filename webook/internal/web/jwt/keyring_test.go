@@ -0,0 +1,148 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestKeyRing_Rotate(t *testing.T) {
+	claims := UserClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Uid: 123,
+	}
+	oldRing, err := NewKeyRing("v1", NewHMACKey("v1", []byte("old secret")))
+	require.NoError(t, err)
+	oldToken, err := oldRing.Sign(claims)
+	require.NoError(t, err)
+
+	// 轮换之后新 key 签名, 旧 key 只用来校验
+	newRing, err := NewKeyRing("v2",
+		NewHMACKey("v1", []byte("old secret")),
+		NewHMACKey("v2", []byte("new secret")))
+	require.NoError(t, err)
+	newToken, err := newRing.Sign(claims)
+	require.NoError(t, err)
+
+	// 没有 kid 的老 token
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
+	legacyToken, err := legacy.SignedString([]byte("old secret"))
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		ring     *KeyRing
+		tokenStr string
+		wantErr  bool
+	}{
+		{
+			name:     "旧 token 用新 ring 校验",
+			ring:     newRing,
+			tokenStr: oldToken,
+		},
+		{
+			name:     "新 token 用新 ring 校验",
+			ring:     newRing,
+			tokenStr: newToken,
+		},
+		{
+			name:     "没有 kid 的老 token",
+			ring:     newRing,
+			tokenStr: legacyToken,
+		},
+		{
+			name:     "旧 ring 不认识新 kid",
+			ring:     oldRing,
+			tokenStr: newToken,
+			wantErr:  true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var uc UserClaims
+			token, err := tc.ring.Parse(tc.tokenStr, &uc)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, token.Valid)
+			assert.Equal(t, int64(123), uc.Uid)
+		})
+	}
+}
+
+func TestNewKeyRing(t *testing.T) {
+	_, err := NewKeyRing("v2", NewHMACKey("v1", []byte("secret")))
+	assert.ErrorIs(t, err, ErrUnknownKid)
+	_, err = NewKeyRing("v1", NewHMACKey("v1", []byte("a")), NewHMACKey("v1", []byte("b")))
+	assert.Error(t, err)
+	_, err = NewHMACKeyRing("v1", map[string]string{"v1": ""})
+	assert.EqualError(t, err, "kid v1 的密钥为空")
+}
+
+func TestKeyRing_Asymmetric(t *testing.T) {
+	rsaPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	rsaPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(rsaPriv),
+	})
+	rsaKey, err := ParsePrivateKeyPEM("rsa-1", "RS256", string(rsaPEM))
+	require.NoError(t, err)
+
+	ring, err := NewKeyRing("rsa-1", rsaKey, NewEd25519Key("ed-1", edPriv))
+	require.NoError(t, err)
+	tokenStr, err := ring.Sign(UserClaims{Uid: 123})
+	require.NoError(t, err)
+
+	var uc UserClaims
+	_, err = ring.Parse(tokenStr, &uc)
+	require.NoError(t, err)
+	assert.Equal(t, int64(123), uc.Uid)
+
+	jwks := ring.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "ed-1", jwks.Keys[0].Kid)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "rsa-1", jwks.Keys[1].Kid)
+	assert.Equal(t, "RS256", jwks.Keys[1].Alg)
+
+	// 其它服务只拿 JWKS 里的公钥就能校验
+	n, err := base64.RawURLEncoding.DecodeString(jwks.Keys[1].N)
+	require.NoError(t, err)
+	e, err := base64.RawURLEncoding.DecodeString(jwks.Keys[1].E)
+	require.NoError(t, err)
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	_, err = jwt.ParseWithClaims(tokenStr, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return pub, nil
+	}, jwt.WithValidMethods([]string{"RS256"}))
+	assert.NoError(t, err)
+
+	// 拿公钥当 HMAC 密钥伪造的 token 不能通过
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS512, UserClaims{Uid: 456})
+	forged.Header["kid"] = "rsa-1"
+	forgedStr, err := forged.SignedString(x509.MarshalPKCS1PublicKey(pub))
+	require.NoError(t, err)
+	_, err = ring.Parse(forgedStr, &UserClaims{})
+	assert.Error(t, err)
+}
+
+func TestKeyRing_JWKSWithoutHMAC(t *testing.T) {
+	ring, err := NewKeyRing("v1", NewHMACKey("v1", []byte("secret")))
+	require.NoError(t, err)
+	assert.Empty(t, ring.JWKS().Keys)
+}
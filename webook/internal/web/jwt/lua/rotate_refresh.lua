@@ -0,0 +1,31 @@
+-- 长 token 轮换, 每个 ssid 维护一个代数, 每刷新一次代数加一
+-- 旧的长 token 再次出现, 说明被人盗用了, 整个会话直接作废
+
+-- 当前代数
+local genKey = KEYS[1]
+-- 作废标记 users:ssid:<ssid>
+local ssidKey = KEYS[2]
+-- 用户的会话列表
+local sessionsKey = KEYS[3]
+
+local gen = tonumber(ARGV[1])
+local expiration = tonumber(ARGV[2])
+local ssid = ARGV[3]
+
+local cur = tonumber(redis.call("get", genKey))
+if cur == nil then
+    -- 会话不存在, 或者已经过期
+    return -2
+end
+
+if cur ~= gen then
+    -- 旧 token 被重放
+    redis.call("set", ssidKey, "", "EX", expiration)
+    redis.call("del", genKey)
+    redis.call("hdel", sessionsKey, ssid)
+    return -1
+end
+
+local next = redis.call("incr", genKey)
+redis.call("expire", genKey, expiration)
+return next
@@ -0,0 +1,198 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webook/internal/web/jwt/types.go
+//
+// Generated by this command:
+//
+//	mockgen -source=webook/internal/web/jwt/types.go -package=jwtmocks -destination=webook/internal/web/jwt/mocks/handler.mock.go
+//
+
+// Package jwtmocks is a generated GoMock package.
+package jwtmocks
+
+import (
+	jwt "basic_go/webook/internal/web/jwt"
+	reflect "reflect"
+
+	gin "github.com/gin-gonic/gin"
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockHandler is a mock of Handler interface.
+type MockHandler struct {
+	ctrl     *gomock.Controller
+	recorder *MockHandlerMockRecorder
+}
+
+// MockHandlerMockRecorder is the mock recorder for MockHandler.
+type MockHandlerMockRecorder struct {
+	mock *MockHandler
+}
+
+// NewMockHandler creates a new mock instance.
+func NewMockHandler(ctrl *gomock.Controller) *MockHandler {
+	mock := &MockHandler{ctrl: ctrl}
+	mock.recorder = &MockHandlerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHandler) EXPECT() *MockHandlerMockRecorder {
+	return m.recorder
+}
+
+// CheckSession mocks base method.
+func (m *MockHandler) CheckSession(ctx *gin.Context, ssid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckSession", ctx, ssid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CheckSession indicates an expected call of CheckSession.
+func (mr *MockHandlerMockRecorder) CheckSession(ctx, ssid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckSession", reflect.TypeOf((*MockHandler)(nil).CheckSession), ctx, ssid)
+}
+
+// ClearToken mocks base method.
+func (m *MockHandler) ClearToken(ctx *gin.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClearToken", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ClearToken indicates an expected call of ClearToken.
+func (mr *MockHandlerMockRecorder) ClearToken(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClearToken", reflect.TypeOf((*MockHandler)(nil).ClearToken), ctx)
+}
+
+// ExtractToken mocks base method.
+func (m *MockHandler) ExtractToken(ctx *gin.Context) string {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ExtractToken", ctx)
+	ret0, _ := ret[0].(string)
+	return ret0
+}
+
+// ExtractToken indicates an expected call of ExtractToken.
+func (mr *MockHandlerMockRecorder) ExtractToken(ctx any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExtractToken", reflect.TypeOf((*MockHandler)(nil).ExtractToken), ctx)
+}
+
+// ListSessions mocks base method.
+func (m *MockHandler) ListSessions(ctx *gin.Context, uid int64) ([]jwt.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSessions", ctx, uid)
+	ret0, _ := ret[0].([]jwt.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSessions indicates an expected call of ListSessions.
+func (mr *MockHandlerMockRecorder) ListSessions(ctx, uid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSessions", reflect.TypeOf((*MockHandler)(nil).ListSessions), ctx, uid)
+}
+
+// ParseAccessToken mocks base method.
+func (m *MockHandler) ParseAccessToken(tokenStr string) (*jwt.UserClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseAccessToken", tokenStr)
+	ret0, _ := ret[0].(*jwt.UserClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseAccessToken indicates an expected call of ParseAccessToken.
+func (mr *MockHandlerMockRecorder) ParseAccessToken(tokenStr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseAccessToken", reflect.TypeOf((*MockHandler)(nil).ParseAccessToken), tokenStr)
+}
+
+// ParseRefreshToken mocks base method.
+func (m *MockHandler) ParseRefreshToken(tokenStr string) (*jwt.RefreshClaims, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParseRefreshToken", tokenStr)
+	ret0, _ := ret[0].(*jwt.RefreshClaims)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ParseRefreshToken indicates an expected call of ParseRefreshToken.
+func (mr *MockHandlerMockRecorder) ParseRefreshToken(tokenStr any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParseRefreshToken", reflect.TypeOf((*MockHandler)(nil).ParseRefreshToken), tokenStr)
+}
+
+// RevokeOtherSessions mocks base method.
+func (m *MockHandler) RevokeOtherSessions(ctx *gin.Context, uid int64, keepSsid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeOtherSessions", ctx, uid, keepSsid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeOtherSessions indicates an expected call of RevokeOtherSessions.
+func (mr *MockHandlerMockRecorder) RevokeOtherSessions(ctx, uid, keepSsid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeOtherSessions", reflect.TypeOf((*MockHandler)(nil).RevokeOtherSessions), ctx, uid, keepSsid)
+}
+
+// RevokeSession mocks base method.
+func (m *MockHandler) RevokeSession(ctx *gin.Context, uid int64, ssid string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RevokeSession", ctx, uid, ssid)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RevokeSession indicates an expected call of RevokeSession.
+func (mr *MockHandlerMockRecorder) RevokeSession(ctx, uid, ssid any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RevokeSession", reflect.TypeOf((*MockHandler)(nil).RevokeSession), ctx, uid, ssid)
+}
+
+// RotateRefreshToken mocks base method.
+func (m *MockHandler) RotateRefreshToken(ctx *gin.Context, rc jwt.RefreshClaims, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RotateRefreshToken", ctx, rc, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RotateRefreshToken indicates an expected call of RotateRefreshToken.
+func (mr *MockHandlerMockRecorder) RotateRefreshToken(ctx, rc, roles any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RotateRefreshToken", reflect.TypeOf((*MockHandler)(nil).RotateRefreshToken), ctx, rc, roles)
+}
+
+// SetJWTToken mocks base method.
+func (m *MockHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetJWTToken", ctx, uid, ssid, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetJWTToken indicates an expected call of SetJWTToken.
+func (mr *MockHandlerMockRecorder) SetJWTToken(ctx, uid, ssid, roles any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetJWTToken", reflect.TypeOf((*MockHandler)(nil).SetJWTToken), ctx, uid, ssid, roles)
+}
+
+// SetLoginToken mocks base method.
+func (m *MockHandler) SetLoginToken(ctx *gin.Context, uid int64, roles []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetLoginToken", ctx, uid, roles)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetLoginToken indicates an expected call of SetLoginToken.
+func (mr *MockHandlerMockRecorder) SetLoginToken(ctx, uid, roles any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLoginToken", reflect.TypeOf((*MockHandler)(nil).SetLoginToken), ctx, uid, roles)
+}
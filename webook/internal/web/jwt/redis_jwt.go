@@ -1,6 +1,7 @@
 package jwt
 
 import (
+	_ "embed"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -11,21 +12,29 @@ import (
 	"time"
 )
 
-var JWTKey = []byte("k6CswdUm77WKcbM68UQUuxVsHSpTCwgK")
-var RCJWTKey = []byte("k6CswdUm77WKcbM68UQUuxVsHSpTCwgA")
+//go:embed lua/rotate_refresh.lua
+var luaRotateRefresh string
+
+var ErrRefreshTokenReused = errors.New("长 token 被重复使用")
 
 type RedisJWTHandler struct {
-	client       redis.Cmdable
+	client redis.Cmdable
+	// accessKeys 签短 token, refreshKeys 签长 token
+	accessKeys   *KeyRing
+	refreshKeys  *KeyRing
 	rcExpiration time.Duration
 }
 
+// ClearToken 清空前端的两个 token, 并且把 ssid 标记为已退出,
+// 之后不管是长 token 还是短 token, CheckSession 都会拒绝
 func (r *RedisJWTHandler) ClearToken(ctx *gin.Context) error {
 	ctx.Header("x-jwt-token", "")
 	ctx.Header("x-refresh-token", "")
-	uc := ctx.MustGet("user").(UserClaims)
-
-	return r.client.Set(ctx, fmt.Sprintf("users:ssid:%s", uc.Ssid), "", r.rcExpiration).Err()
-
+	uc, ok := ctx.MustGet(ClaimsKey).(*UserClaims)
+	if !ok {
+		return errors.New("登录信息不存在")
+	}
+	return r.revoke(ctx, uc.Uid, uc.Ssid)
 }
 
 func (r *RedisJWTHandler) ExtractToken(ctx *gin.Context) string {
@@ -40,16 +49,45 @@ func (r *RedisJWTHandler) ExtractToken(ctx *gin.Context) string {
 	return segs[1]
 }
 
-func (r *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64) error {
+func (r *RedisJWTHandler) SetLoginToken(ctx *gin.Context, uid int64, roles []string) error {
 	ssid := uuid.New().String()
-	err := r.setRefreshToken(ctx, uid, ssid)
+	// 新会话的长 token 从第一代开始
+	const firstGen = 1
+	err := r.client.Set(ctx, r.genKey(ssid), firstGen, r.rcExpiration).Err()
+	if err != nil {
+		return err
+	}
+	err = r.setRefreshToken(ctx, uid, ssid, firstGen)
+	if err != nil {
+		return err
+	}
+	return r.SetJWTToken(ctx, uid, ssid, roles)
+}
+
+// RotateRefreshToken 用长 token 换一对新的长短 token, 旧的长 token 立刻失效
+// 如果拿来的是已经换过的旧长 token, 整个会话都会被吊销, 返回 ErrRefreshTokenReused
+// roles 由调用方重新查一遍, 这样授权变更在下次刷新的时候就能生效
+func (r *RedisJWTHandler) RotateRefreshToken(ctx *gin.Context, rc RefreshClaims, roles []string) error {
+	gen, err := r.client.Eval(ctx, luaRotateRefresh,
+		[]string{r.genKey(rc.Ssid), r.ssidKey(rc.Ssid), r.sessionsKey(rc.Uid)},
+		rc.Gen, int64(r.rcExpiration.Seconds()), rc.Ssid).Int64()
+	if err != nil {
+		return err
+	}
+	switch gen {
+	case -2:
+		return ErrSessionNotFound
+	case -1:
+		return ErrRefreshTokenReused
+	}
+	err = r.setRefreshToken(ctx, rc.Uid, rc.Ssid, gen)
 	if err != nil {
 		return err
 	}
-	return r.SetJWTToken(ctx, uid, ssid)
+	return r.SetJWTToken(ctx, rc.Uid, rc.Ssid, roles)
 }
 
-func (r *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string) error {
+func (r *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string, roles []string) error {
 	claims := UserClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 7 * 24)),
@@ -57,26 +95,27 @@ func (r *RedisJWTHandler) SetJWTToken(ctx *gin.Context, uid int64, ssid string)
 		Uid:       uid,
 		Ssid:      ssid,
 		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+		Roles:     roles,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	tokenStr, err := token.SignedString(JWTKey)
+	tokenStr, err := r.accessKeys.Sign(claims)
 	if err != nil {
 		return err
 	}
 	ctx.Header("x-jwt-token", tokenStr)
-	return nil
+	return r.touchSession(ctx, uid, ssid)
 }
 
-func (r *RedisJWTHandler) setRefreshToken(ctx *gin.Context, uid int64, ssid string) error {
+func (r *RedisJWTHandler) setRefreshToken(ctx *gin.Context, uid int64, ssid string, gen int64) error {
 	claims := RefreshClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 30)),
 		},
 		Uid:  uid,
 		Ssid: ssid,
+		Gen:  gen,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, claims)
-	tokenStr, err := token.SignedString(RCJWTKey)
+	tokenStr, err := r.refreshKeys.Sign(claims)
 	if err != nil {
 		return err
 	}
@@ -84,8 +123,32 @@ func (r *RedisJWTHandler) setRefreshToken(ctx *gin.Context, uid int64, ssid stri
 	return nil
 }
 
+func (r *RedisJWTHandler) ParseAccessToken(tokenStr string) (*UserClaims, error) {
+	claims := &UserClaims{}
+	token, err := r.accessKeys.Parse(tokenStr, claims)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || !token.Valid {
+		return nil, errors.New("token 无效")
+	}
+	return claims, nil
+}
+
+func (r *RedisJWTHandler) ParseRefreshToken(tokenStr string) (*RefreshClaims, error) {
+	claims := &RefreshClaims{}
+	token, err := r.refreshKeys.Parse(tokenStr, claims)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || !token.Valid {
+		return nil, errors.New("token 无效")
+	}
+	return claims, nil
+}
+
 func (r *RedisJWTHandler) CheckSession(ctx *gin.Context, ssid string) error {
-	cnt, err := r.client.Exists(ctx, fmt.Sprintf("users:ssid:%s", ssid)).Result()
+	cnt, err := r.client.Exists(ctx, r.ssidKey(ssid)).Result()
 	if err != nil {
 		return err
 	}
@@ -95,9 +158,19 @@ func (r *RedisJWTHandler) CheckSession(ctx *gin.Context, ssid string) error {
 	return nil
 }
 
-func NewRedisJWTHandler(cmd redis.Cmdable) Handler {
+func (r *RedisJWTHandler) ssidKey(ssid string) string {
+	return fmt.Sprintf("users:ssid:%s", ssid)
+}
+
+func (r *RedisJWTHandler) genKey(ssid string) string {
+	return fmt.Sprintf("users:ssid:%s:gen", ssid)
+}
+
+func NewRedisJWTHandler(cmd redis.Cmdable, accessKeys *KeyRing, refreshKeys *KeyRing) Handler {
 	return &RedisJWTHandler{
 		client:       cmd,
+		accessKeys:   accessKeys,
+		refreshKeys:  refreshKeys,
 		rcExpiration: time.Hour * 24 * 7,
 	}
 }
@@ -107,10 +180,15 @@ type UserClaims struct {
 	Ssid      string
 	Uid       int64
 	UserAgent string
+	// IP 签发 token 时客户端的 IP, 给绑定检查用
+	IP    string
+	Roles []string
 }
 
 type RefreshClaims struct {
 	jwt.RegisteredClaims
 	Ssid string
 	Uid  int64
+	// Gen 长 token 的代数, 和 redis 里的对不上就是旧 token
+	Gen int64
 }
@@ -0,0 +1,68 @@
+package jwt
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedisJWTHandler_RotateRefreshToken(t *testing.T) {
+	mr := miniredis.RunT(t)
+	hdl := NewRedisJWTHandler(redis.NewClient(&redis.Options{Addr: mr.Addr()}),
+		newTestKeyRing(t, "access"), newTestKeyRing(t, "refresh"))
+
+	ctx, resp := newTestContext(t)
+	err := hdl.SetLoginToken(ctx, 123, nil)
+	require.NoError(t, err)
+	first := parseRefreshToken(t, hdl, resp.Header().Get("x-refresh-token"))
+	assert.Equal(t, int64(1), first.Gen)
+
+	// 第一次刷新, 拿到第二代长 token
+	ctx, resp = newTestContext(t)
+	err = hdl.RotateRefreshToken(ctx, first, nil)
+	require.NoError(t, err)
+	second := parseRefreshToken(t, hdl, resp.Header().Get("x-refresh-token"))
+	assert.Equal(t, int64(2), second.Gen)
+	assert.Equal(t, first.Ssid, second.Ssid)
+	assert.NotEmpty(t, resp.Header().Get("x-jwt-token"))
+
+	// 第一代长 token 被重放, 整个会话作废
+	ctx, _ = newTestContext(t)
+	err = hdl.RotateRefreshToken(ctx, first, nil)
+	assert.Equal(t, ErrRefreshTokenReused, err)
+	assert.Error(t, hdl.CheckSession(ctx, first.Ssid))
+
+	// 合法的第二代长 token 也不能再用了
+	ctx, _ = newTestContext(t)
+	err = hdl.RotateRefreshToken(ctx, second, nil)
+	assert.Equal(t, ErrSessionNotFound, err)
+	sessions, err := hdl.ListSessions(ctx, 123)
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+}
+
+func newTestContext(t *testing.T) (*gin.Context, *httptest.ResponseRecorder) {
+	resp := httptest.NewRecorder()
+	ctx, _ := gin.CreateTestContext(resp)
+	req, err := http.NewRequest(http.MethodGet, "/users/refresh_token", nil)
+	require.NoError(t, err)
+	ctx.Request = req
+	return ctx, resp
+}
+
+func parseRefreshToken(t *testing.T, hdl Handler, tokenStr string) RefreshClaims {
+	rc, err := hdl.ParseRefreshToken(tokenStr)
+	require.NoError(t, err)
+	return *rc
+}
+
+func newTestKeyRing(t *testing.T, secret string) *KeyRing {
+	ring, err := NewKeyRing("v1", NewHMACKey("v1", []byte(secret)))
+	require.NoError(t, err)
+	return ring
+}
@@ -0,0 +1,132 @@
+package jwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("登录会话不存在")
+
+// Session 一次登录就是一个 session, 用 ssid 区分不同设备
+type Session struct {
+	Ssid      string `json:"ssid"`
+	UserAgent string `json:"userAgent"`
+	IP        string `json:"ip"`
+	// Ctime 登录时间, Utime 最近一次刷新 token 的时间, 都是毫秒数
+	Ctime int64 `json:"ctime"`
+	Utime int64 `json:"utime"`
+}
+
+// ListSessions 列出用户所有还有效的登录会话, 按最近活跃时间倒序
+// 已经过期的会话会顺手清理掉
+func (r *RedisJWTHandler) ListSessions(ctx *gin.Context, uid int64) ([]Session, error) {
+	vals, err := r.client.HGetAll(ctx, r.sessionsKey(uid)).Result()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	res := make([]Session, 0, len(vals))
+	var expired []string
+	for ssid, val := range vals {
+		var s Session
+		err = json.Unmarshal([]byte(val), &s)
+		if err != nil || now-s.Utime > r.rcExpiration.Milliseconds() {
+			expired = append(expired, ssid)
+			continue
+		}
+		res = append(res, s)
+	}
+	if len(expired) > 0 {
+		// 清理失败不影响查询结果
+		_ = r.client.HDel(ctx, r.sessionsKey(uid), expired...).Err()
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Utime > res[j].Utime
+	})
+	return res, nil
+}
+
+// RevokeSession 踢掉用户的某一个会话, ssid 必须属于这个用户
+func (r *RedisJWTHandler) RevokeSession(ctx *gin.Context, uid int64, ssid string) error {
+	ok, err := r.client.HExists(ctx, r.sessionsKey(uid), ssid).Result()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	return r.revoke(ctx, uid, ssid)
+}
+
+// RevokeOtherSessions 踢掉除了 keepSsid 之外的所有会话, keepSsid 为空就是全部踢掉
+func (r *RedisJWTHandler) RevokeOtherSessions(ctx *gin.Context, uid int64, keepSsid string) error {
+	ssids, err := r.client.HKeys(ctx, r.sessionsKey(uid)).Result()
+	if err != nil {
+		return err
+	}
+	var revoked []string
+	for _, ssid := range ssids {
+		if ssid != keepSsid {
+			revoked = append(revoked, ssid)
+		}
+	}
+	return r.revoke(ctx, uid, revoked...)
+}
+
+// revoke 写入 users:ssid:<ssid>, 让 CheckSession 拒绝这些会话, 并从会话列表里删掉
+func (r *RedisJWTHandler) revoke(ctx *gin.Context, uid int64, ssids ...string) error {
+	if len(ssids) == 0 {
+		return nil
+	}
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, ssid := range ssids {
+			pipe.Set(ctx, r.ssidKey(ssid), "", r.rcExpiration)
+			pipe.Del(ctx, r.genKey(ssid))
+		}
+		pipe.HDel(ctx, r.sessionsKey(uid), ssids...)
+		return nil
+	})
+	return err
+}
+
+// touchSession 登录的时候创建会话, 刷新 token 的时候更新最近活跃时间
+func (r *RedisJWTHandler) touchSession(ctx *gin.Context, uid int64, ssid string) error {
+	key := r.sessionsKey(uid)
+	now := time.Now().UnixMilli()
+	s := Session{
+		Ssid:      ssid,
+		UserAgent: ctx.Request.UserAgent(),
+		IP:        ctx.ClientIP(),
+		Ctime:     now,
+		Utime:     now,
+	}
+	val, err := r.client.HGet(ctx, key, ssid).Bytes()
+	switch {
+	case err == nil:
+		var old Session
+		if json.Unmarshal(val, &old) == nil {
+			s.Ctime = old.Ctime
+		}
+	case !errors.Is(err, redis.Nil):
+		return err
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, ssid, data)
+		pipe.Expire(ctx, key, r.rcExpiration)
+		return nil
+	})
+	return err
+}
+
+func (r *RedisJWTHandler) sessionsKey(uid int64) string {
+	return fmt.Sprintf("users:sessions:%d", uid)
+}
@@ -2,10 +2,19 @@ package jwt
 
 import "github.com/gin-gonic/gin"
 
+// ClaimsKey 登录校验通过后, *UserClaims 放在 gin.Context 里的 key
+const ClaimsKey = "users"
+
 type Handler interface {
 	ClearToken(ctx *gin.Context) error
 	ExtractToken(ctx *gin.Context) string
-	SetLoginToken(ctx *gin.Context, uid int64) error
-	SetJWTToken(ctx *gin.Context, uid int64, ssid string) error
+	SetLoginToken(ctx *gin.Context, uid int64, roles []string) error
+	SetJWTToken(ctx *gin.Context, uid int64, ssid string, roles []string) error
+	RotateRefreshToken(ctx *gin.Context, rc RefreshClaims, roles []string) error
 	CheckSession(ctx *gin.Context, ssid string) error
+	ParseAccessToken(tokenStr string) (*UserClaims, error)
+	ParseRefreshToken(tokenStr string) (*RefreshClaims, error)
+	ListSessions(ctx *gin.Context, uid int64) ([]Session, error)
+	RevokeSession(ctx *gin.Context, uid int64, ssid string) error
+	RevokeOtherSessions(ctx *gin.Context, uid int64, keepSsid string) error
 }
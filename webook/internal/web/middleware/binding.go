@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	ijwt "basic_go/webook/internal/web/jwt"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net"
+)
+
+// BindingMode token 和客户端绑定的检查力度
+type BindingMode uint8
+
+const (
+	// BindingOff 不检查
+	BindingOff BindingMode = iota
+	// BindingWarn 不一致的时候记录安全日志, 但是放行
+	BindingWarn
+	// BindingReject 不一致直接拒绝
+	BindingReject
+)
+
+func ParseBindingMode(s string) (BindingMode, error) {
+	switch s {
+	case "", "off":
+		return BindingOff, nil
+	case "warn":
+		return BindingWarn, nil
+	case "reject":
+		return BindingReject, nil
+	default:
+		return BindingOff, fmt.Errorf("未知的绑定模式 %s", s)
+	}
+}
+
+// BindingPolicy 检查请求是不是来自签发 token 的那个客户端, 用来发现被盗用的 token
+// IP 用的是 gin 的 ClientIP, 引擎要设置好可信的代理, 不然带上受害者 IP 的 X-Forwarded-For 就能通过检查
+type BindingPolicy struct {
+	UserAgent BindingMode
+	IP        BindingMode
+	// IPv4MaskBits 和 IPv6MaskBits 在同一个网段就认为是同一个客户端,
+	// 0 表示必须完全一致, 比如手机切换基站 IP 会变, 可以放宽到 /24
+	IPv4MaskBits int
+	IPv6MaskBits int
+}
+
+// check 返回 false 表示要拒绝这个请求
+func (p BindingPolicy) check(ctx *gin.Context, claims *ijwt.UserClaims) bool {
+	ok := p.apply(ctx, claims, p.UserAgent, "user-agent",
+		claims.UserAgent, ctx.Request.UserAgent(), claims.UserAgent == ctx.Request.UserAgent())
+	// 老 token 里面没有 IP, 不检查
+	if claims.IP != "" {
+		ok = p.apply(ctx, claims, p.IP, "ip",
+			claims.IP, ctx.ClientIP(), p.sameSubnet(claims.IP, ctx.ClientIP())) && ok
+	}
+	return ok
+}
+
+func (p BindingPolicy) apply(ctx *gin.Context, claims *ijwt.UserClaims, mode BindingMode,
+	field, expected, actual string, matched bool) bool {
+	if mode == BindingOff || matched {
+		return true
+	}
+	// 安全事件, 后面接入告警的时候按照 [security] 过滤
+	log.Printf("[security] token 绑定不一致, field: %s, uid: %d, ssid: %s, path: %s, expected: %q, actual: %q, reject: %t",
+		field, claims.Uid, claims.Ssid, ctx.Request.URL.Path, expected, actual, mode == BindingReject)
+	return mode != BindingReject
+}
+
+func (p BindingPolicy) sameSubnet(expected, actual string) bool {
+	if expected == actual {
+		return true
+	}
+	ip1, ip2 := net.ParseIP(expected), net.ParseIP(actual)
+	if ip1 == nil || ip2 == nil {
+		return false
+	}
+	bits, total := p.IPv6MaskBits, 128
+	if ip1.To4() != nil {
+		if ip2.To4() == nil {
+			return false
+		}
+		ip1, ip2 = ip1.To4(), ip2.To4()
+		bits, total = p.IPv4MaskBits, 32
+	}
+	if bits <= 0 {
+		return false
+	}
+	mask := net.CIDRMask(bits, total)
+	if mask == nil {
+		return false
+	}
+	return ip1.Mask(mask).Equal(ip2.Mask(mask))
+}
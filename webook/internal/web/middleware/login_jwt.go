@@ -2,14 +2,16 @@ package middleware
 
 import (
 	ijwt "basic_go/webook/internal/web/jwt"
+	"basic_go/webook/pkg/route"
 	"github.com/gin-gonic/gin"
-	jwt "github.com/golang-jwt/jwt/v5"
 	"net/http"
 	"strings"
 )
 
 type LoginJWTMiddlewareBuilder struct {
-	paths []string
+	ignored route.Rules
+	public  publicRoutes
+	binding BindingPolicy
 	ijwt.Handler
 }
 
@@ -21,10 +23,8 @@ func NewLoginJWTMiddlewareBuilder(hdl ijwt.Handler) *LoginJWTMiddlewareBuilder {
 
 func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		for _, path := range l.paths {
-			if ctx.Request.URL.Path == path {
-				return
-			}
+		if l.ignored.Match(ctx.Request.Method, ctx.Request.URL.Path) || l.public.isPublic(ctx) {
+			return
 		}
 		tokenHeader := ctx.GetHeader("Authorization")
 		if tokenHeader == "" {
@@ -38,15 +38,12 @@ func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 			return
 		}
 		tokenStr := segs[1]
-		claims := &ijwt.UserClaims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return ijwt.JWTKey, nil
-		})
+		claims, err := l.ParseAccessToken(tokenStr)
 		if err != nil {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		if token == nil || !token.Valid || claims.Uid == 0 {
+		if claims.Uid == 0 {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
@@ -55,12 +52,26 @@ func (l *LoginJWTMiddlewareBuilder) Build() gin.HandlerFunc {
 			ctx.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		if !l.binding.check(ctx, claims) {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
 
-		ctx.Set("users", claims)
+		ctx.Set(ijwt.ClaimsKey, claims)
 	}
 }
 
-func (l *LoginJWTMiddlewareBuilder) IgnorePaths(path string) *LoginJWTMiddlewareBuilder {
-	l.paths = append(l.paths, path)
+// IgnorePaths 不需要登录的路径, 写法见 route.Rule, 比如 "GET /articles/*"
+// 路由自己可以用 Public 声明, 这里只放不方便在 RegisterRoutes 里声明的
+func (l *LoginJWTMiddlewareBuilder) IgnorePaths(paths ...string) *LoginJWTMiddlewareBuilder {
+	for _, p := range paths {
+		l.ignored = append(l.ignored, route.MustParseRule(p))
+	}
+	return l
+}
+
+// Binding 设置 token 绑定策略, 默认不检查
+func (l *LoginJWTMiddlewareBuilder) Binding(p BindingPolicy) *LoginJWTMiddlewareBuilder {
+	l.binding = p
 	return l
 }
@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	ijwt "basic_go/webook/internal/web/jwt"
+	jwtmocks "basic_go/webook/internal/web/jwt/mocks"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoginJWTMiddlewareBuilder_Binding(t *testing.T) {
+	testCases := []struct {
+		name      string
+		policy    BindingPolicy
+		claims    ijwt.UserClaims
+		userAgent string
+		remoteIP  string
+		// forwardedFor 客户端自己带的 X-Forwarded-For
+		forwardedFor string
+		wantCode     int
+	}{
+		{
+			name:      "不检查",
+			policy:    BindingPolicy{},
+			claims:    ijwt.UserClaims{Uid: 123, UserAgent: "chrome", IP: "10.0.0.1"},
+			userAgent: "curl",
+			remoteIP:  "192.168.0.1",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "user-agent 不一致只告警",
+			policy:    BindingPolicy{UserAgent: BindingWarn},
+			claims:    ijwt.UserClaims{Uid: 123, UserAgent: "chrome"},
+			userAgent: "curl",
+			remoteIP:  "10.0.0.1",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "user-agent 不一致拒绝",
+			policy:    BindingPolicy{UserAgent: BindingReject},
+			claims:    ijwt.UserClaims{Uid: 123, UserAgent: "chrome"},
+			userAgent: "curl",
+			remoteIP:  "10.0.0.1",
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "user-agent 一致",
+			policy:    BindingPolicy{UserAgent: BindingReject},
+			claims:    ijwt.UserClaims{Uid: 123, UserAgent: "chrome"},
+			userAgent: "chrome",
+			remoteIP:  "10.0.0.1",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "同一个网段",
+			policy:    BindingPolicy{IP: BindingReject, IPv4MaskBits: 24},
+			claims:    ijwt.UserClaims{Uid: 123, IP: "10.0.0.1"},
+			userAgent: "chrome",
+			remoteIP:  "10.0.0.200",
+			wantCode:  http.StatusOK,
+		},
+		{
+			name:      "不同网段拒绝",
+			policy:    BindingPolicy{IP: BindingReject, IPv4MaskBits: 24},
+			claims:    ijwt.UserClaims{Uid: 123, IP: "10.0.0.1"},
+			userAgent: "chrome",
+			remoteIP:  "10.0.1.1",
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:      "没有掩码必须完全一致",
+			policy:    BindingPolicy{IP: BindingReject},
+			claims:    ijwt.UserClaims{Uid: 123, IP: "10.0.0.1"},
+			userAgent: "chrome",
+			remoteIP:  "10.0.0.2",
+			wantCode:  http.StatusUnauthorized,
+		},
+		{
+			name:         "伪造 X-Forwarded-For 冒充签发时的 IP",
+			policy:       BindingPolicy{IP: BindingReject},
+			claims:       ijwt.UserClaims{Uid: 123, IP: "10.0.0.1"},
+			userAgent:    "chrome",
+			remoteIP:     "203.0.113.7",
+			forwardedFor: "10.0.0.1",
+			wantCode:     http.StatusUnauthorized,
+		},
+		{
+			name:      "老 token 没有 IP",
+			policy:    BindingPolicy{IP: BindingReject},
+			claims:    ijwt.UserClaims{Uid: 123},
+			userAgent: "chrome",
+			remoteIP:  "10.0.0.2",
+			wantCode:  http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			hdl := jwtmocks.NewMockHandler(ctrl)
+			claims := tc.claims
+			hdl.EXPECT().ParseAccessToken("token").Return(&claims, nil)
+			hdl.EXPECT().CheckSession(gomock.Any(), gomock.Any()).Return(nil)
+
+			server := gin.New()
+			// 和 main 里一样, 没有配置代理就不认请求头里的 IP
+			require.NoError(t, server.SetTrustedProxies(nil))
+			server.Use(NewLoginJWTMiddlewareBuilder(hdl).Binding(tc.policy).Build())
+			server.GET("/users/profile", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+
+			req, err := http.NewRequest(http.MethodGet, "/users/profile", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("User-Agent", tc.userAgent)
+			req.RemoteAddr = tc.remoteIP + ":12345"
+			if tc.forwardedFor != "" {
+				req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+			}
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
+
+func TestLoginJWTMiddlewareBuilder_Ignore(t *testing.T) {
+	testCases := []struct {
+		name     string
+		method   string
+		path     string
+		wantCode int
+	}{
+		{
+			name:     "路由声明了 Public",
+			method:   http.MethodPost,
+			path:     "/users/signup",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "GET 文章公开",
+			method:   http.MethodGet,
+			path:     "/articles/123",
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "POST 文章要登录",
+			method:   http.MethodPost,
+			path:     "/articles/123",
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "没有声明 Public",
+			method:   http.MethodGet,
+			path:     "/users/profile",
+			wantCode: http.StatusUnauthorized,
+		},
+	}
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	server := gin.New()
+	server.Use(NewLoginJWTMiddlewareBuilder(jwtmocks.NewMockHandler(ctrl)).
+		IgnorePaths("GET /articles/*").Build())
+	ok := func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	}
+	server.POST("/users/signup", Public, ok)
+	server.GET("/users/profile", ok)
+	server.GET("/articles/:id", ok)
+	server.POST("/articles/:id", ok)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// 跑两遍, 第二遍走缓存
+			for i := 0; i < 2; i++ {
+				req, err := http.NewRequest(tc.method, tc.path, nil)
+				require.NoError(t, err)
+				resp := httptest.NewRecorder()
+				server.ServeHTTP(resp, req)
+				assert.Equal(t, tc.wantCode, resp.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// Public 标记路由不需要登录, 在 RegisterRoutes 里放在业务 handler 前面:
+//
+//	ug.POST("/signup", middleware.Public, u.Signup)
+//
+// 它本身什么都不做, 登录校验的 middleware 看到路由上挂了它就直接放行
+func Public(ctx *gin.Context) {}
+
+var publicName = runtime.FuncForPC(reflect.ValueOf(Public).Pointer()).Name()
+
+// publicRoutes 缓存路由是不是 Public, 避免每次请求都反射拿 handler 名字
+type publicRoutes struct {
+	cache sync.Map
+}
+
+func (p *publicRoutes) isPublic(ctx *gin.Context) bool {
+	fullPath := ctx.FullPath()
+	// 没匹配上路由, 交给后面 404
+	if fullPath == "" {
+		return false
+	}
+	key := ctx.Request.Method + " " + fullPath
+	if val, ok := p.cache.Load(key); ok {
+		return val.(bool)
+	}
+	res := false
+	for _, name := range ctx.HandlerNames() {
+		if name == publicName {
+			res = true
+			break
+		}
+	}
+	p.cache.Store(key, res)
+	return res
+}
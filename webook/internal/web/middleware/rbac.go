@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"basic_go/webook/internal/domain"
+	ijwt "basic_go/webook/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// RequireRole 必须放在登录校验后面, 有其中任意一个角色才能访问
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := claimsFrom(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !domain.HasRole(claims.Roles, roles...) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+// RequirePermission 按照角色对应的权限检查, 见 domain.HasPermission
+func RequirePermission(perm string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		claims, ok := claimsFrom(ctx)
+		if !ok {
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !domain.HasPermission(claims.Roles, perm) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+	}
+}
+
+func claimsFrom(ctx *gin.Context) (*ijwt.UserClaims, bool) {
+	val, ok := ctx.Get(ijwt.ClaimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := val.(*ijwt.UserClaims)
+	return claims, ok
+}
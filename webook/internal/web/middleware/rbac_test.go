@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"basic_go/webook/internal/domain"
+	ijwt "basic_go/webook/internal/web/jwt"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRBAC(t *testing.T) {
+	testCases := []struct {
+		name     string
+		claims   *ijwt.UserClaims
+		check    gin.HandlerFunc
+		wantCode int
+	}{
+		{
+			name:     "没有登录",
+			check:    RequireRole(domain.RoleAdmin),
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "普通用户",
+			claims:   &ijwt.UserClaims{Uid: 123},
+			check:    RequireRole(domain.RoleAdmin),
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "管理员",
+			claims:   &ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleAdmin}},
+			check:    RequireRole(domain.RoleAdmin),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "任意一个角色",
+			claims:   &ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleOperator}},
+			check:    RequireRole(domain.RoleAdmin, domain.RoleOperator),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "运营可以查看用户",
+			claims:   &ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleOperator}},
+			check:    RequirePermission(domain.PermUserRead),
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "运营不能封号",
+			claims:   &ijwt.UserClaims{Uid: 123, Roles: []string{domain.RoleOperator}},
+			check:    RequirePermission(domain.PermUserBan),
+			wantCode: http.StatusForbidden,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := gin.New()
+			server.Use(func(ctx *gin.Context) {
+				if tc.claims != nil {
+					ctx.Set(ijwt.ClaimsKey, tc.claims)
+				}
+			})
+			server.GET("/admin/users", tc.check, func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req, err := http.NewRequest(http.MethodGet, "/admin/users", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+		})
+	}
+}
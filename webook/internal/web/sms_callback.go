@@ -0,0 +1,131 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/web/middleware"
+	"crypto/subtle"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"time"
+)
+
+// smsReportTimeLayout 腾讯云和阿里云回调里的时间都是这个格式, 北京时间
+const smsReportTimeLayout = "2006-01-02 15:04:05"
+
+// SMSCallbackHandler 接收服务商推送的送达状态
+// 服务商的回调没有签名, 回调地址上要带 token 参数, 在服务商的控制台里配置成
+// https://域名/sms/callback/tencent?token=xxx
+type SMSCallbackHandler struct {
+	svc   service.SMSLogService
+	token string
+}
+
+func NewSMSCallbackHandler(svc service.SMSLogService, token string) *SMSCallbackHandler {
+	return &SMSCallbackHandler{
+		svc:   svc,
+		token: token,
+	}
+}
+
+func (h *SMSCallbackHandler) RegisterRoutes(server *gin.Engine) {
+	g := server.Group("/sms/callback")
+	g.POST("/tencent", middleware.Public, h.checkToken, h.Tencent)
+	g.POST("/aliyun", middleware.Public, h.checkToken, h.Aliyun)
+}
+
+// checkToken 没有配置 token 的时候全部拒绝
+func (h *SMSCallbackHandler) checkToken(ctx *gin.Context) {
+	token := ctx.Query("token")
+	if h.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+}
+
+// Tencent 腾讯云的状态回执, 响应格式是腾讯云规定的
+func (h *SMSCallbackHandler) Tencent(ctx *gin.Context) {
+	type Report struct {
+		UserReceiveTime string `json:"user_receive_time"`
+		ReportStatus    string `json:"report_status"`
+		ErrMsg          string `json:"errmsg"`
+		Description     string `json:"description"`
+		Sid             string `json:"sid"`
+	}
+	var reports []Report
+	if err := ctx.ShouldBindJSON(&reports); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"result": 1, "errmsg": "参数错误"})
+		return
+	}
+	for _, r := range reports {
+		d := domain.SMSDelivery{
+			Provider:   "tencent",
+			BizId:      r.Sid,
+			Delivered:  r.ReportStatus == "SUCCESS",
+			ReportTime: parseSMSReportTime(r.UserReceiveTime),
+		}
+		if !d.Delivered {
+			d.Err = r.ErrMsg + " " + r.Description
+		}
+		if !h.updateDelivery(ctx, d) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"result": 1, "errmsg": "系统错误"})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"result": 0, "errmsg": "OK"})
+}
+
+// Aliyun 阿里云的短信发送状态报告, 响应格式是阿里云规定的
+func (h *SMSCallbackHandler) Aliyun(ctx *gin.Context) {
+	type Report struct {
+		ReportTime string `json:"report_time"`
+		Success    bool   `json:"success"`
+		ErrCode    string `json:"err_code"`
+		ErrMsg     string `json:"err_msg"`
+		BizId      string `json:"biz_id"`
+	}
+	var reports []Report
+	if err := ctx.ShouldBindJSON(&reports); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"code": 1, "msg": "参数错误"})
+		return
+	}
+	for _, r := range reports {
+		d := domain.SMSDelivery{
+			Provider:   "aliyun",
+			BizId:      r.BizId,
+			Delivered:  r.Success,
+			ReportTime: parseSMSReportTime(r.ReportTime),
+		}
+		if !d.Delivered {
+			d.Err = r.ErrCode + " " + r.ErrMsg
+		}
+		if !h.updateDelivery(ctx, d) {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"code": 1, "msg": "系统错误"})
+			return
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"code": 0, "msg": "接收成功"})
+}
+
+// updateDelivery 返回 false 的时候让服务商稍后重推
+func (h *SMSCallbackHandler) updateDelivery(ctx *gin.Context, d domain.SMSDelivery) bool {
+	// 没有回执 id 的对不上发送记录, 重推也没用
+	if d.BizId == "" {
+		return true
+	}
+	if err := h.svc.UpdateDelivery(ctx, d); err != nil {
+		log.Println("更新短信送达状态失败", d.Provider, d.BizId, err)
+		return false
+	}
+	return true
+}
+
+// parseSMSReportTime 解析不了就用收到回调的时间
+func parseSMSReportTime(s string) time.Time {
+	t, err := time.ParseInLocation(smsReportTimeLayout, s, time.FixedZone("CST", 8*3600))
+	if err != nil {
+		return time.Now()
+	}
+	return t
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"basic_go/webook/internal/domain"
+	"basic_go/webook/internal/service"
+	svcmocks "basic_go/webook/internal/service/mocks"
+	"bytes"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/mock/gomock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSMSCallbackHandler(t *testing.T) {
+	cst := time.FixedZone("CST", 8*3600)
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.SMSLogService
+		token    string
+		url      string
+		body     string
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "腾讯云送达和没送达",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().UpdateDelivery(gomock.Any(), domain.SMSDelivery{
+					Provider:   "tencent",
+					BizId:      "sid-1",
+					Delivered:  true,
+					ReportTime: time.Date(2026, 10, 1, 8, 3, 4, 0, cst),
+				}).Return(nil)
+				svc.EXPECT().UpdateDelivery(gomock.Any(), domain.SMSDelivery{
+					Provider:   "tencent",
+					BizId:      "sid-2",
+					Err:        "MK:0001 用户停机",
+					ReportTime: time.Date(2026, 10, 1, 8, 3, 5, 0, cst),
+				}).Return(nil)
+				return svc
+			},
+			token: "token",
+			url:   "/sms/callback/tencent?token=token",
+			body: `[{"user_receive_time":"2026-10-01 08:03:04","mobile":"13800000000","report_status":"SUCCESS",` +
+				`"errmsg":"DELIVRD","description":"用户短信送达成功","sid":"sid-1"},` +
+				`{"user_receive_time":"2026-10-01 08:03:05","mobile":"13800000001","report_status":"FAIL",` +
+				`"errmsg":"MK:0001","description":"用户停机","sid":"sid-2"}]`,
+			wantCode: http.StatusOK,
+			wantBody: `{"errmsg":"OK","result":0}`,
+		},
+		{
+			name: "阿里云送达",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().UpdateDelivery(gomock.Any(), domain.SMSDelivery{
+					Provider:   "aliyun",
+					BizId:      "biz-1",
+					Delivered:  true,
+					ReportTime: time.Date(2026, 10, 1, 11, 37, 31, 0, cst),
+				}).Return(nil)
+				return svc
+			},
+			token: "token",
+			url:   "/sms/callback/aliyun?token=token",
+			body: `[{"phone_number":"13800000000","send_time":"2026-10-01 11:37:29",` +
+				`"report_time":"2026-10-01 11:37:31","success":true,"err_code":"DELIVERED",` +
+				`"err_msg":"用户接收成功","sms_size":"1","biz_id":"biz-1"}]`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"接收成功"}`,
+		},
+		{
+			name: "没有回执 id 的跳过",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			token:    "token",
+			url:      "/sms/callback/aliyun?token=token",
+			body:     `[{"report_time":"2026-10-01 11:37:31","success":true}]`,
+			wantCode: http.StatusOK,
+			wantBody: `{"code":0,"msg":"接收成功"}`,
+		},
+		{
+			name: "更新失败让服务商重推",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				svc := svcmocks.NewMockSMSLogService(ctrl)
+				svc.EXPECT().UpdateDelivery(gomock.Any(), gomock.Any()).Return(errors.New("db error"))
+				return svc
+			},
+			token:    "token",
+			url:      "/sms/callback/aliyun?token=token",
+			body:     `[{"report_time":"2026-10-01 11:37:31","success":true,"biz_id":"biz-1"}]`,
+			wantCode: http.StatusInternalServerError,
+			wantBody: `{"code":1,"msg":"系统错误"}`,
+		},
+		{
+			name: "token 不对",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			token:    "token",
+			url:      "/sms/callback/tencent?token=wrong",
+			body:     `[]`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name: "没有配置 token",
+			mock: func(ctrl *gomock.Controller) service.SMSLogService {
+				return svcmocks.NewMockSMSLogService(ctrl)
+			},
+			url:      "/sms/callback/tencent?token=",
+			body:     `[]`,
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			NewSMSCallbackHandler(tc.mock(ctrl), tc.token).RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			if tc.wantBody != "" {
+				assert.Equal(t, tc.wantBody, resp.Body.String())
+			}
+		})
+	}
+}
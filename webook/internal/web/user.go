@@ -4,35 +4,50 @@ import (
 	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/service"
 	ijwt "basic_go/webook/internal/web/jwt"
+	"basic_go/webook/internal/web/middleware"
+	"basic_go/webook/pkg/clientip"
+	"context"
 	"errors"
 	regexp "github.com/dlclark/regexp2"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"log"
 	"net/http"
+	"time"
+	"unicode/utf8"
 )
 
 const (
 	emailRegexPattern    = "^\\w+([-+.]\\w+)*@\\w+([-.]\\w+)*\\.\\w+([-.]\\w+)*$"
 	passwordRegexPattern = `^(?=.*[A-Za-z])(?=.*\d)(?=.*[$@$!%*#?&])[A-Za-z\d$@$!%*#?&]{8,}$`
 	biz                  = "login"
+	bizResetPassword     = "reset_pwd"
+	bizVerifyEmail       = "verify_email"
+	bizBind              = "bind"
+	nicknameMaxLen       = 36
+	aboutMeMaxLen        = 1024
+	birthdayLayout       = "2006-01-02"
 )
 
 // UserHandler 定义和用户有关的所有路由
 type UserHandler struct {
 	svc              service.UserService
 	codeSvc          service.CodeService
+	emailCodeSvc     service.CodeService
 	emailRegexExp    *regexp.Regexp
 	passwordRegexExp *regexp.Regexp
 	ijwt.Handler
 }
 
-func NewUserHandler(svc service.UserService, codeSvc service.CodeService, hdl ijwt.Handler) *UserHandler {
+// NewUserHandler codeSvc 发短信验证码, emailCodeSvc 发邮件验证码
+func NewUserHandler(svc service.UserService, codeSvc service.CodeService,
+	emailCodeSvc service.CodeService, hdl ijwt.Handler) *UserHandler {
 	return &UserHandler{
 		emailRegexExp:    regexp.MustCompile(emailRegexPattern, regexp.None),
 		passwordRegexExp: regexp.MustCompile(passwordRegexPattern, regexp.None),
 		svc:              svc,
 		codeSvc:          codeSvc,
+		emailCodeSvc:     emailCodeSvc,
 		Handler:          hdl,
 	}
 }
@@ -40,12 +55,29 @@ func NewUserHandler(svc service.UserService, codeSvc service.CodeService, hdl ij
 func (u *UserHandler) RegisterRoutes(server *gin.Engine) {
 	ug := server.Group("/users")
 	ug.GET("/profile", u.ProfileJWT)
-	ug.POST("/signup", u.Signup)
-	ug.POST("/login", u.LoginJWT)
+	ug.POST("/signup", middleware.Public, u.Signup)
+	ug.POST("/login", middleware.Public, u.LoginJWT)
+	ug.POST("/logout", u.LogoutJWT)
 	ug.POST("/edit", u.Edit)
-	ug.POST("/login_sms/code/send", u.SendLoginSmsCode)
-	ug.POST("/login_sms", u.LoginSMS)
-	ug.GET("/refresh_token", u.RefreshToken)
+	ug.POST("/login_sms/code/send", middleware.Public, u.SendLoginSmsCode)
+	ug.POST("/login_sms", middleware.Public, u.LoginSMS)
+	ug.POST("/login_email/code/send", middleware.Public, u.SendLoginEmailCode)
+	ug.POST("/login_email", middleware.Public, u.LoginEmail)
+	ug.POST("/email/verify/code/send", u.SendVerifyEmailCode)
+	ug.POST("/email/verify", u.VerifyEmail)
+	// 带的是长 token, 自己校验
+	ug.GET("/refresh_token", middleware.Public, u.RefreshToken)
+	ug.POST("/password/change", u.ChangePassword)
+	ug.POST("/password/reset/code/send", middleware.Public, u.SendResetPasswordCode)
+	ug.POST("/password/reset", middleware.Public, u.ResetPassword)
+	ug.POST("/bind/phone/code/send", u.SendBindPhoneCode)
+	ug.POST("/bind/phone", u.BindPhone)
+	ug.POST("/bind/email/code/send", u.SendBindEmailCode)
+	ug.POST("/bind/email", u.BindEmail)
+	ug.POST("/unbind", u.Unbind)
+	ug.GET("/sessions", u.ListSessions)
+	ug.POST("/sessions/revoke", u.RevokeSession)
+	ug.POST("/sessions/revoke_others", u.RevokeOtherSessions)
 }
 
 func (u *UserHandler) Signup(ctx *gin.Context) {
@@ -91,6 +123,13 @@ func (u *UserHandler) Signup(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "邮箱冲突")
 		return
 	}
+	if err == nil {
+		// 验证邮件发不出去不影响注册, 用户可以登录之后重新发
+		err = u.emailCodeSvc.Send(ctx, bizVerifyEmail, req.Email)
+		if err != nil {
+			log.Println("发送邮箱验证码失败", err)
+		}
+	}
 	ctx.String(http.StatusOK, "注册成功")
 }
 
@@ -130,7 +169,70 @@ func (u *UserHandler) Login(ctx *gin.Context) {
 }
 
 func (u *UserHandler) Edit(ctx *gin.Context) {
-
+	type EditReq struct {
+		Nickname string `json:"nickname"`
+		Birthday string `json:"birthday"`
+		AboutMe  string `json:"aboutMe"`
+	}
+	var req EditReq
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if utf8.RuneCountInString(req.Nickname) > nicknameMaxLen {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "昵称过长",
+		})
+		return
+	}
+	if utf8.RuneCountInString(req.AboutMe) > aboutMeMaxLen {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "个人简介过长",
+		})
+		return
+	}
+	var birthday time.Time
+	if req.Birthday != "" {
+		var err error
+		birthday, err = time.ParseInLocation(birthdayLayout, req.Birthday, time.Local)
+		if err != nil {
+			ctx.JSON(http.StatusOK, &Result{
+				Code:    4,
+				Message: "生日格式不对",
+			})
+			return
+		}
+		if birthday.After(time.Now()) {
+			ctx.JSON(http.StatusOK, &Result{
+				Code:    4,
+				Message: "生日不能晚于今天",
+			})
+			return
+		}
+	}
+	err := u.svc.UpdateNonSensitiveInfo(ctx, domain.User{
+		Id:       c.Uid,
+		Nickname: req.Nickname,
+		Birthday: birthday,
+		AboutMe:  req.AboutMe,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "修改成功",
+	})
 }
 
 func (u *UserHandler) Profile(ctx *gin.Context) {
@@ -159,11 +261,19 @@ func (u *UserHandler) Profile(ctx *gin.Context) {
 			Id:       user.Id,
 			Email:    user.Email,
 			Nickname: user.Nickname,
-			Birthday: user.Birthday.Format("2006-01-02 15:04:05"),
+			Birthday: u.formatBirthday(user.Birthday),
+			AboutMe:  user.AboutMe,
 		},
 	})
 }
 
+func (u *UserHandler) formatBirthday(birthday time.Time) string {
+	if birthday.IsZero() {
+		return ""
+	}
+	return birthday.Format(birthdayLayout)
+}
+
 func (u *UserHandler) Logout(ctx *gin.Context) {
 	sess := sessions.Default(ctx)
 	sess.Options(sessions.Options{
@@ -176,6 +286,21 @@ func (u *UserHandler) Logout(ctx *gin.Context) {
 	})
 }
 
+func (u *UserHandler) LogoutJWT(ctx *gin.Context) {
+	err := u.ClearToken(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "退出登录成功",
+	})
+}
+
 func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 	type LoginReq struct {
 		Email    string `json:"email"`
@@ -189,7 +314,7 @@ func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 	user, err := u.svc.Login(ctx, req.Email, req.Password)
 	switch {
 	case err == nil:
-		err = u.SetLoginToken(ctx, user.Id)
+		err = u.SetLoginToken(ctx, user.Id, user.Roles)
 		if err != nil {
 			ctx.JSON(http.StatusOK, &Result{
 				Code:    1,
@@ -206,17 +331,17 @@ func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 			Code:    1,
 			Message: "用户名或密码不对",
 		})
+	case errors.Is(err, service.ErrUserBanned):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    6,
+			Message: "账号已被封禁",
+		})
 	default:
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    2,
 			Message: "系统错误",
 		})
 	}
-	ctx.JSON(http.StatusOK, &Result{
-		Code:    0,
-		Message: "登录成功",
-	})
-	return
 }
 
 //func (u *UserHandler) setJWTToken(ctx *gin.Context, uid int64) {
@@ -236,7 +361,7 @@ func (u *UserHandler) LoginJWT(ctx *gin.Context) {
 //}
 
 func (u *UserHandler) ProfileJWT(ctx *gin.Context) {
-	c, ok := ctx.MustGet("users").(ijwt.UserClaims)
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
 	if !ok {
 		//ctx.String(http.StatusOK, "系统错误"
 		ctx.AbortWithStatus(http.StatusUnauthorized)
@@ -252,21 +377,24 @@ func (u *UserHandler) ProfileJWT(ctx *gin.Context) {
 		return
 	}
 	type Response struct {
-		Id       int64
-		Email    string
-		Nickname string
-		Birthday string
-		AboutMe  string
+		Id            int64
+		Email         string
+		EmailVerified bool
+		Nickname      string
+		Birthday      string
+		AboutMe       string
 	}
 	//fmt.Println(user.Nickname)
 	ctx.JSON(http.StatusOK, &Result{
 		Code:    0,
 		Message: "ok",
 		Data: &Response{
-			Id:       user.Id,
-			Email:    user.Email,
-			Nickname: user.Nickname,
-			Birthday: user.Birthday.Format("2006-01-02 15:04:05"),
+			Id:            user.Id,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			Nickname:      user.Nickname,
+			Birthday:      u.formatBirthday(user.Birthday),
+			AboutMe:       user.AboutMe,
 		},
 	})
 }
@@ -284,8 +412,13 @@ func (u *UserHandler) SendLoginSmsCode(ctx *gin.Context) {
 			Code:    1,
 			Message: "手机号码为空",
 		})
+		return
+	}
+	err := u.codeSvc.Send(smsCodeCtx(ctx), biz, req.Phone)
+	if res, ok := smsLimitResult(err); ok {
+		ctx.JSON(http.StatusOK, res)
+		return
 	}
-	err := u.codeSvc.Send(ctx, biz, req.Phone)
 	switch {
 	case err == nil:
 		ctx.JSON(http.StatusOK, &Result{
@@ -331,6 +464,13 @@ func (u *UserHandler) LoginSMS(ctx *gin.Context) {
 		return
 	}
 	ud, err := u.svc.FindOrCreate(ctx, req.Phone)
+	if errors.Is(err, service.ErrUserBanned) {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    6,
+			Message: "账号已被封禁",
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    3,
@@ -338,7 +478,7 @@ func (u *UserHandler) LoginSMS(ctx *gin.Context) {
 		})
 		return
 	}
-	err = u.SetLoginToken(ctx, ud.Id)
+	err = u.SetLoginToken(ctx, ud.Id, ud.Roles)
 	if err != nil {
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    3,
@@ -354,25 +494,27 @@ func (u *UserHandler) LoginSMS(ctx *gin.Context) {
 
 func (u *UserHandler) RefreshToken(ctx *gin.Context) {
 	tokenStr := u.ExtractToken(ctx)
-	var rc ijwt.RefreshClaims
-	token, err := jwt.ParseWithClaims(tokenStr, &rc, func(token *jwt.Token) (interface{}, error) {
-		return ijwt.RCJWTKey, nil
-	})
+	rc, err := u.ParseRefreshToken(tokenStr)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	if token == nil || !token.Valid {
+	err = u.CheckSession(ctx, rc.Ssid)
+	if err != nil {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	err = u.CheckSession(ctx, rc.Ssid)
-	if err != nil {
+	// 重新查一遍角色, 授权变更在刷新之后生效
+	user, err := u.svc.Profile(ctx, rc.Uid)
+	if err != nil || user.Status == domain.UserStatusBanned {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
-	err = u.SetJWTToken(ctx, rc.Uid, rc.Ssid)
+	err = u.RotateRefreshToken(ctx, *rc, user.Roles)
 	if err != nil {
+		if errors.Is(err, ijwt.ErrRefreshTokenReused) {
+			log.Printf("长 token 被重放, uid: %d, ssid: %s", rc.Uid, rc.Ssid)
+		}
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
 	}
@@ -380,3 +522,636 @@ func (u *UserHandler) RefreshToken(ctx *gin.Context) {
 		Message: "OK",
 	})
 }
+
+// ListSessions 查看当前用户在哪些设备上登录了
+func (u *UserHandler) ListSessions(ctx *gin.Context) {
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	sessions, err := u.Handler.ListSessions(ctx, c.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	type SessionVo struct {
+		Ssid      string `json:"ssid"`
+		UserAgent string `json:"userAgent"`
+		IP        string `json:"ip"`
+		Ctime     int64  `json:"ctime"`
+		Utime     int64  `json:"utime"`
+		// Current 是不是发起这次请求的会话
+		Current bool `json:"current"`
+	}
+	res := make([]SessionVo, 0, len(sessions))
+	for _, s := range sessions {
+		res = append(res, SessionVo{
+			Ssid:      s.Ssid,
+			UserAgent: s.UserAgent,
+			IP:        s.IP,
+			Ctime:     s.Ctime,
+			Utime:     s.Utime,
+			Current:   s.Ssid == c.Ssid,
+		})
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+		Data:    res,
+	})
+}
+
+// RevokeSession 踢掉某一个设备上的登录
+func (u *UserHandler) RevokeSession(ctx *gin.Context) {
+	type Req struct {
+		Ssid string `json:"ssid"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	err := u.Handler.RevokeSession(ctx, c.Uid, req.Ssid)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "ok",
+		})
+	case errors.Is(err, ijwt.ErrSessionNotFound):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "登录会话不存在",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
+
+// RevokeOtherSessions 只保留当前设备, 其它设备全部退出登录
+func (u *UserHandler) RevokeOtherSessions(ctx *gin.Context) {
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	err := u.Handler.RevokeOtherSessions(ctx, c.Uid, c.Ssid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "ok",
+	})
+}
+
+// ChangePassword 修改密码, 成功之后其它设备上的登录全部失效
+func (u *UserHandler) ChangePassword(ctx *gin.Context) {
+	type Req struct {
+		OldPassword     string `json:"oldPassword"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	if !u.checkNewPassword(ctx, req.Password, req.ConfirmPassword) {
+		return
+	}
+	err := u.svc.ChangePassword(ctx, c.Uid, req.OldPassword, req.Password)
+	if errors.Is(err, service.ErrInvalidPassword) {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "原密码不对",
+		})
+		return
+	}
+	if err == nil {
+		err = u.Handler.RevokeOtherSessions(ctx, c.Uid, c.Ssid)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "修改密码成功",
+	})
+}
+
+// SendResetPasswordCode 忘记密码, 先给手机发验证码
+func (u *UserHandler) SendResetPasswordCode(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	u.sendSMSCode(ctx, bizResetPassword, req.Phone)
+}
+
+// ResetPassword 校验验证码之后重置密码, 并且踢掉这个用户所有的登录
+func (u *UserHandler) ResetPassword(ctx *gin.Context) {
+	type Req struct {
+		Phone           string `json:"phone"`
+		Code            string `json:"code"`
+		Password        string `json:"password"`
+		ConfirmPassword string `json:"confirmPassword"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	if !u.checkNewPassword(ctx, req.Password, req.ConfirmPassword) {
+		return
+	}
+	ok, err := u.codeSvc.Verify(ctx, bizResetPassword, req.Phone, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "验证码不对，请重新输入",
+		})
+		return
+	}
+	user, err := u.svc.ResetPasswordByPhone(ctx, req.Phone, req.Password)
+	if errors.Is(err, service.ErrUserNotFound) {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "用户不存在",
+		})
+		return
+	}
+	if err == nil {
+		err = u.Handler.RevokeOtherSessions(ctx, user.Id, "")
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "重置密码成功",
+	})
+}
+
+// checkNewPassword 校验新密码的格式, 不通过的时候已经写好了响应
+func (u *UserHandler) checkNewPassword(ctx *gin.Context, password string, confirmPassword string) bool {
+	if password != confirmPassword {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "两次密码不一致",
+		})
+		return false
+	}
+	isPassword, err := u.passwordRegexExp.MatchString(password)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return false
+	}
+	if !isPassword {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "密码必须包含字母、数字、特殊字符, 并且不少于8位",
+		})
+		return false
+	}
+	return true
+}
+
+func (u *UserHandler) SendLoginEmailCode(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	isEmail, err := u.emailRegexExp.MatchString(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !isEmail {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "非法邮箱格式",
+		})
+		return
+	}
+	u.sendEmailCode(ctx, biz, req.Email)
+}
+
+// LoginEmail 邮箱验证码登录, 不需要密码, 没有注册过的邮箱直接注册
+func (u *UserHandler) LoginEmail(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	ok, err := u.emailCodeSvc.Verify(ctx, biz, req.Email, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "验证码不对，请重新输入",
+		})
+		return
+	}
+	ud, newlyVerified, err := u.svc.FindOrCreateByEmail(ctx, req.Email)
+	if errors.Is(err, service.ErrUserBanned) {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    6,
+			Message: "账号已被封禁",
+		})
+		return
+	}
+	if err == nil && newlyVerified {
+		// 之前用密码登录的可能是冒用这个邮箱注册的人
+		err = u.Handler.RevokeOtherSessions(ctx, ud.Id, "")
+	}
+	if err == nil {
+		err = u.SetLoginToken(ctx, ud.Id, ud.Roles)
+	}
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, Result{
+		Code:    0,
+		Message: "登录成功",
+	})
+}
+
+// SendVerifyEmailCode 注册时候的验证邮件没收到, 登录之后重新发
+func (u *UserHandler) SendVerifyEmailCode(ctx *gin.Context) {
+	user, ok := u.unverifiedEmailUser(ctx)
+	if !ok {
+		return
+	}
+	u.sendEmailCode(ctx, bizVerifyEmail, user.Email)
+}
+
+func (u *UserHandler) VerifyEmail(ctx *gin.Context) {
+	type Req struct {
+		Code string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	user, ok := u.unverifiedEmailUser(ctx)
+	if !ok {
+		return
+	}
+	ok, err := u.emailCodeSvc.Verify(ctx, bizVerifyEmail, user.Email, req.Code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "验证码不对，请重新输入",
+		})
+		return
+	}
+	err = u.svc.VerifyEmail(ctx, user.Id)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, &Result{
+		Code:    0,
+		Message: "邮箱验证成功",
+	})
+}
+
+// unverifiedEmailUser 查出当前用户, 要求有邮箱并且还没验证过, 不满足的时候已经写好了响应
+func (u *UserHandler) unverifiedEmailUser(ctx *gin.Context) (domain.User, bool) {
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return domain.User{}, false
+	}
+	user, err := u.svc.Profile(ctx, c.Uid)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return domain.User{}, false
+	}
+	if user.Email == "" {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "还没有绑定邮箱",
+		})
+		return domain.User{}, false
+	}
+	if user.EmailVerified {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "邮箱已经验证过了",
+		})
+		return domain.User{}, false
+	}
+	return user, true
+}
+
+func (u *UserHandler) sendEmailCode(ctx *gin.Context, biz string, email string) {
+	err := u.emailCodeSvc.Send(ctx, biz, email)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "发送验证码成功",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    2,
+			Message: "邮件发送太频繁, 请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
+
+func (u *UserHandler) SendBindPhoneCode(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	u.sendSMSCode(ctx, bizBind, req.Phone)
+}
+
+// BindPhone 给当前用户绑定手机号, 之后可以用短信登录同一个账号
+func (u *UserHandler) BindPhone(ctx *gin.Context) {
+	type Req struct {
+		Phone string `json:"phone"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	u.bind(ctx, u.codeSvc, req.Phone, req.Code, func(uid int64) error {
+		return u.svc.BindPhone(ctx, uid, req.Phone)
+	})
+}
+
+func (u *UserHandler) SendBindEmailCode(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	isEmail, err := u.emailRegexExp.MatchString(req.Email)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !isEmail {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "非法邮箱格式",
+		})
+		return
+	}
+	u.sendEmailCode(ctx, bizBind, req.Email)
+}
+
+func (u *UserHandler) BindEmail(ctx *gin.Context) {
+	type Req struct {
+		Email string `json:"email"`
+		Code  string `json:"code"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	u.bind(ctx, u.emailCodeSvc, req.Email, req.Code, func(uid int64) error {
+		return u.svc.BindEmail(ctx, uid, req.Email)
+	})
+}
+
+// Unbind method 是 email, phone, wechat 其中一个, 最后一种登录方式不能解绑
+func (u *UserHandler) Unbind(ctx *gin.Context) {
+	type Req struct {
+		Method string `json:"method"`
+	}
+	var req Req
+	if err := ctx.Bind(&req); err != nil {
+		return
+	}
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	method := domain.LoginMethod(req.Method)
+	switch method {
+	case domain.LoginMethodEmail, domain.LoginMethodPhone, domain.LoginMethodWechat:
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "未知的登录方式",
+		})
+		return
+	}
+	err := u.svc.Unbind(ctx, c.Uid, method)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "解绑成功",
+		})
+	case errors.Is(err, service.ErrLastLoginMethod):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "不能解绑最后一种登录方式",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
+
+// bind 校验验证码之后调用 bindFn 绑定到当前用户
+func (u *UserHandler) bind(ctx *gin.Context, codeSvc service.CodeService,
+	target string, code string, bindFn func(uid int64) error) {
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	ok, err := codeSvc.Verify(ctx, bizBind, target, code)
+	if err != nil {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "验证码不对，请重新输入",
+		})
+		return
+	}
+	err = bindFn(c.Uid)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "绑定成功",
+		})
+	case errors.Is(err, service.ErrIdentityTaken):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "已经被其它账号绑定",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
+
+func (u *UserHandler) sendSMSCode(ctx *gin.Context, biz string, phone string) {
+	if phone == "" {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "手机号码为空",
+		})
+		return
+	}
+	err := u.codeSvc.Send(smsCodeCtx(ctx), biz, phone)
+	if res, ok := smsLimitResult(err); ok {
+		ctx.JSON(http.StatusOK, res)
+		return
+	}
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    0,
+			Message: "发送验证码成功",
+		})
+	case errors.Is(err, service.ErrCodeSendTooMany):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    2,
+			Message: "短信发送太频繁, 请稍后再试",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    5,
+			Message: "系统错误",
+		})
+	}
+}
+
+// smsCodeCtx 带上客户端 IP 和图形验证码的通行 token, 给限流和图形验证码检查用
+func smsCodeCtx(ctx *gin.Context) context.Context {
+	c := clientip.WithIP(ctx, ctx.ClientIP())
+	return service.WithCaptchaToken(c, ctx.GetHeader(captchaTokenHeader))
+}
+
+// smsLimitResult 各层短信限流对应的响应, 不是限流的错误返回 false
+func smsLimitResult(err error) (*Result, bool) {
+	switch {
+	case errors.Is(err, service.ErrCaptchaRequired):
+		return &Result{
+			Code:    10,
+			Message: "请先完成图形验证码",
+		}, true
+	case errors.Is(err, service.ErrCaptchaInvalid):
+		return &Result{
+			Code:    11,
+			Message: "图形验证码无效, 请重新获取",
+		}, true
+	case errors.Is(err, service.ErrCodeSendPhoneLimited):
+		return &Result{
+			Code:    7,
+			Message: "这个手机号今天收到的短信太多了, 请明天再试",
+		}, true
+	case errors.Is(err, service.ErrCodeSendIPLimited):
+		return &Result{
+			Code:    8,
+			Message: "发送短信太多了, 请稍后再试",
+		}, true
+	}
+	return nil, false
+}
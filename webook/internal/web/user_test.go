@@ -4,8 +4,12 @@ import (
 	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/service"
 	svcmocks "basic_go/webook/internal/service/mocks"
+	ijwt "basic_go/webook/internal/web/jwt"
+	jwtmocks "basic_go/webook/internal/web/jwt/mocks"
+	"basic_go/webook/pkg/clientip"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -14,7 +18,9 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncrypt(t *testing.T) {
@@ -153,7 +159,11 @@ func TestUserHandler_Signup(t *testing.T) {
 			ctrl := gomock.NewController(t)
 			defer ctrl.Finish()
 			server := gin.Default()
-			h := NewUserHandler(tc.mock(ctrl), nil)
+			// 注册成功之后会发邮箱验证码
+			emailCodeSvc := svcmocks.NewMockCodeService(ctrl)
+			emailCodeSvc.EXPECT().Send(gomock.Any(), "verify_email", "123@qq.com").
+				Return(nil).AnyTimes()
+			h := NewUserHandler(tc.mock(ctrl), nil, emailCodeSvc, nil)
 			h.RegisterRoutes(server)
 
 			req, err := http.NewRequest(http.MethodPost, "/users/signup", bytes.NewBuffer([]byte(tc.reqBody)))
@@ -168,6 +178,719 @@ func TestUserHandler_Signup(t *testing.T) {
 	}
 }
 
+func TestUserHandler_Edit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) service.UserService
+		reqBody  string
+		wantCode int
+		wantBody Result
+	}{
+		{
+			name: "修改成功",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().UpdateNonSensitiveInfo(gomock.Any(), domain.User{
+					Id:       123,
+					Nickname: "大明",
+					Birthday: time.Date(2000, 1, 2, 0, 0, 0, 0, time.Local),
+					AboutMe:  "一个程序员",
+				}).Return(nil)
+				return usersvc
+			},
+			reqBody: `
+					{
+						"nickname": "大明",
+						"birthday": "2000-01-02",
+						"aboutMe": "一个程序员"
+					}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 0, Message: "修改成功"},
+		},
+		{
+			name: "不传生日",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().UpdateNonSensitiveInfo(gomock.Any(), domain.User{
+					Id:       123,
+					Nickname: "大明",
+				}).Return(nil)
+				return usersvc
+			},
+			reqBody:  `{"nickname": "大明"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 0, Message: "修改成功"},
+		},
+		{
+			name: "昵称过长",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			reqBody:  `{"nickname": "` + strings.Repeat("明", 37) + `"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "昵称过长"},
+		},
+		{
+			name: "生日格式不对",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			reqBody:  `{"birthday": "2000/01/02"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "生日格式不对"},
+		},
+		{
+			name: "生日在未来",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			reqBody:  `{"birthday": "` + time.Now().AddDate(1, 0, 0).Format("2006-01-02") + `"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "生日不能晚于今天"},
+		},
+		{
+			name: "个人简介过长",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				return svcmocks.NewMockUserService(ctrl)
+			},
+			reqBody:  `{"aboutMe": "` + strings.Repeat("a", 1025) + `"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 4, Message: "个人简介过长"},
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) service.UserService {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().UpdateNonSensitiveInfo(gomock.Any(), gomock.Any()).
+					Return(errors.New("db error"))
+				return usersvc
+			},
+			reqBody:  `{"nickname": "大明"}`,
+			wantCode: http.StatusOK,
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 123})
+			})
+			h := NewUserHandler(tc.mock(ctrl), nil, nil, nil)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/edit", bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantCode, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_LogoutJWT(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) ijwt.Handler
+		wantBody Result
+	}{
+		{
+			name: "退出成功",
+			mock: func(ctrl *gomock.Controller) ijwt.Handler {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().ClearToken(gomock.Any()).Return(nil)
+				return hdl
+			},
+			wantBody: Result{Code: 0, Message: "退出登录成功"},
+		},
+		{
+			name: "写 redis 失败",
+			mock: func(ctrl *gomock.Controller) ijwt.Handler {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().ClearToken(gomock.Any()).Return(errors.New("redis error"))
+				return hdl
+			},
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			h := NewUserHandler(nil, nil, nil, tc.mock(ctrl))
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/logout", nil)
+			require.NoError(t, err)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_RevokeSession(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) ijwt.Handler
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "踢掉成功",
+			mock: func(ctrl *gomock.Controller) ijwt.Handler {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().RevokeSession(gomock.Any(), int64(123), "ssid-2").Return(nil)
+				return hdl
+			},
+			reqBody:  `{"ssid": "ssid-2"}`,
+			wantBody: Result{Code: 0, Message: "ok"},
+		},
+		{
+			name: "不是自己的会话",
+			mock: func(ctrl *gomock.Controller) ijwt.Handler {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().RevokeSession(gomock.Any(), int64(123), "ssid-other").
+					Return(ijwt.ErrSessionNotFound)
+				return hdl
+			},
+			reqBody:  `{"ssid": "ssid-other"}`,
+			wantBody: Result{Code: 4, Message: "登录会话不存在"},
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) ijwt.Handler {
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				hdl.EXPECT().RevokeSession(gomock.Any(), int64(123), "ssid-2").
+					Return(errors.New("redis error"))
+				return hdl
+			},
+			reqBody:  `{"ssid": "ssid-2"}`,
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 123, Ssid: "ssid-1"})
+			})
+			h := NewUserHandler(nil, nil, nil, tc.mock(ctrl))
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/sessions/revoke", bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_ResetPassword(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler)
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "重置成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "reset_pwd", "13800000000", "123456").Return(true, nil)
+				usersvc.EXPECT().ResetPasswordByPhone(gomock.Any(), "13800000000", "hello#world123").
+					Return(domain.User{Id: 123}, nil)
+				hdl.EXPECT().RevokeOtherSessions(gomock.Any(), int64(123), "").Return(nil)
+				return usersvc, codesvc, hdl
+			},
+			reqBody: `{"phone": "13800000000", "code": "123456",
+"password": "hello#world123", "confirmPassword": "hello#world123"}`,
+			wantBody: Result{Code: 0, Message: "重置密码成功"},
+		},
+		{
+			name: "两次密码不一致",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				return nil, nil, nil
+			},
+			reqBody: `{"phone": "13800000000", "code": "123456",
+"password": "hello#world123", "confirmPassword": "hello#world1234"}`,
+			wantBody: Result{Code: 4, Message: "两次密码不一致"},
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "reset_pwd", "13800000000", "654321").Return(false, nil)
+				return nil, codesvc, nil
+			},
+			reqBody: `{"phone": "13800000000", "code": "654321",
+"password": "hello#world123", "confirmPassword": "hello#world123"}`,
+			wantBody: Result{Code: 4, Message: "验证码不对，请重新输入"},
+		},
+		{
+			name: "用户不存在",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "reset_pwd", "13800000000", "123456").Return(true, nil)
+				usersvc.EXPECT().ResetPasswordByPhone(gomock.Any(), "13800000000", "hello#world123").
+					Return(domain.User{}, service.ErrUserNotFound)
+				return usersvc, codesvc, nil
+			},
+			reqBody: `{"phone": "13800000000", "code": "123456",
+"password": "hello#world123", "confirmPassword": "hello#world123"}`,
+			wantBody: Result{Code: 4, Message: "用户不存在"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			usersvc, codesvc, hdl := tc.mock(ctrl)
+			h := NewUserHandler(usersvc, codesvc, nil, hdl)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/password/reset", bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_LoginJWT(t *testing.T) {
+	testCases := []struct {
+		name string
+		mock func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler)
+		// wantBody 比较整个响应, 确保只写了一次
+		wantBody string
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				usersvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{Id: 123}, nil)
+				hdl.EXPECT().SetLoginToken(gomock.Any(), int64(123), gomock.Any()).Return(nil)
+				return usersvc, hdl
+			},
+			wantBody: `{"code":0,"message":"登录成功","data":null}`,
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{}, service.ErrUserBanned)
+				return usersvc, nil
+			},
+			wantBody: `{"code":6,"message":"账号已被封禁","data":null}`,
+		},
+		{
+			name: "密码不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{}, service.ErrInvalidUserOrPassword)
+				return usersvc, nil
+			},
+			wantBody: `{"code":1,"message":"用户名或密码不对","data":null}`,
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Login(gomock.Any(), "123@qq.com", "hello#world123").
+					Return(domain.User{}, errors.New("db error"))
+				return usersvc, nil
+			},
+			wantBody: `{"code":2,"message":"系统错误","data":null}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			usersvc, hdl := tc.mock(ctrl)
+			h := NewUserHandler(usersvc, nil, nil, hdl)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login",
+				bytes.NewBuffer([]byte(`{"email": "123@qq.com", "password": "hello#world123"}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, tc.wantBody, resp.Body.String())
+		})
+	}
+}
+
+func TestUserHandler_ChangePassword(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler)
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "修改成功, 其他设备下线",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				usersvc.EXPECT().ChangePassword(gomock.Any(), int64(123), "hello#world123", "hello#world456").
+					Return(nil)
+				hdl.EXPECT().RevokeOtherSessions(gomock.Any(), int64(123), "ssid").Return(nil)
+				return usersvc, hdl
+			},
+			reqBody: `{"oldPassword": "hello#world123",
+"password": "hello#world456", "confirmPassword": "hello#world456"}`,
+			wantBody: Result{Code: 0, Message: "修改密码成功"},
+		},
+		{
+			name: "原密码不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().ChangePassword(gomock.Any(), int64(123), "hello#world000", "hello#world456").
+					Return(service.ErrInvalidPassword)
+				return usersvc, nil
+			},
+			reqBody: `{"oldPassword": "hello#world000",
+"password": "hello#world456", "confirmPassword": "hello#world456"}`,
+			wantBody: Result{Code: 4, Message: "原密码不对"},
+		},
+		{
+			name: "两次密码不一致",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				return nil, nil
+			},
+			reqBody: `{"oldPassword": "hello#world123",
+"password": "hello#world456", "confirmPassword": "hello#world457"}`,
+			wantBody: Result{Code: 4, Message: "两次密码不一致"},
+		},
+		{
+			name: "系统错误",
+			mock: func(ctrl *gomock.Controller) (service.UserService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().ChangePassword(gomock.Any(), int64(123), "hello#world123", "hello#world456").
+					Return(errors.New("db error"))
+				return usersvc, nil
+			},
+			reqBody: `{"oldPassword": "hello#world123",
+"password": "hello#world456", "confirmPassword": "hello#world456"}`,
+			wantBody: Result{Code: 5, Message: "系统错误"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 123, Ssid: "ssid"})
+			})
+			usersvc, hdl := tc.mock(ctrl)
+			h := NewUserHandler(usersvc, nil, nil, hdl)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/password/change", bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_LoginEmail(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler)
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "登录成功",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "login", "123@qq.com", "123456").Return(true, nil)
+				usersvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123}, false, nil)
+				hdl.EXPECT().SetLoginToken(gomock.Any(), int64(123), gomock.Any()).Return(nil)
+				return usersvc, codesvc, hdl
+			},
+			reqBody:  `{"email": "123@qq.com", "code": "123456"}`,
+			wantBody: Result{Code: 0, Message: "登录成功"},
+		},
+		{
+			name: "第一次验证邮箱, 踢掉已有的登录",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				hdl := jwtmocks.NewMockHandler(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "login", "123@qq.com", "123456").Return(true, nil)
+				usersvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{Id: 123}, true, nil)
+				gomock.InOrder(
+					hdl.EXPECT().RevokeOtherSessions(gomock.Any(), int64(123), "").Return(nil),
+					hdl.EXPECT().SetLoginToken(gomock.Any(), int64(123), gomock.Any()).Return(nil),
+				)
+				return usersvc, codesvc, hdl
+			},
+			reqBody:  `{"email": "123@qq.com", "code": "123456"}`,
+			wantBody: Result{Code: 0, Message: "登录成功"},
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "login", "123@qq.com", "654321").Return(false, nil)
+				return nil, codesvc, nil
+			},
+			reqBody:  `{"email": "123@qq.com", "code": "654321"}`,
+			wantBody: Result{Code: 4, Message: "验证码不对，请重新输入"},
+		},
+		{
+			name: "账号被封禁",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService, ijwt.Handler) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "login", "123@qq.com", "123456").Return(true, nil)
+				usersvc.EXPECT().FindOrCreateByEmail(gomock.Any(), "123@qq.com").
+					Return(domain.User{}, false, service.ErrUserBanned)
+				return usersvc, codesvc, nil
+			},
+			reqBody:  `{"email": "123@qq.com", "code": "123456"}`,
+			wantBody: Result{Code: 6, Message: "账号已被封禁"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			usersvc, emailCodeSvc, hdl := tc.mock(ctrl)
+			h := NewUserHandler(usersvc, nil, emailCodeSvc, hdl)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_email", bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_Bind(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mock     func(ctrl *gomock.Controller) (service.UserService, service.CodeService)
+		url      string
+		reqBody  string
+		wantBody Result
+	}{
+		{
+			name: "绑定手机号",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "bind", "13800000000", "123456").Return(true, nil)
+				usersvc.EXPECT().BindPhone(gomock.Any(), int64(123), "13800000000").Return(nil)
+				return usersvc, codesvc
+			},
+			url:      "/users/bind/phone",
+			reqBody:  `{"phone": "13800000000", "code": "123456"}`,
+			wantBody: Result{Code: 0, Message: "绑定成功"},
+		},
+		{
+			name: "手机号已经被别人绑定",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "bind", "13800000000", "123456").Return(true, nil)
+				usersvc.EXPECT().BindPhone(gomock.Any(), int64(123), "13800000000").
+					Return(service.ErrIdentityTaken)
+				return usersvc, codesvc
+			},
+			url:      "/users/bind/phone",
+			reqBody:  `{"phone": "13800000000", "code": "123456"}`,
+			wantBody: Result{Code: 4, Message: "已经被其它账号绑定"},
+		},
+		{
+			name: "验证码不对",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				codesvc := svcmocks.NewMockCodeService(ctrl)
+				codesvc.EXPECT().Verify(gomock.Any(), "bind", "13800000000", "654321").Return(false, nil)
+				return svcmocks.NewMockUserService(ctrl), codesvc
+			},
+			url:      "/users/bind/phone",
+			reqBody:  `{"phone": "13800000000", "code": "654321"}`,
+			wantBody: Result{Code: 4, Message: "验证码不对，请重新输入"},
+		},
+		{
+			name: "解绑最后一种登录方式",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				usersvc := svcmocks.NewMockUserService(ctrl)
+				usersvc.EXPECT().Unbind(gomock.Any(), int64(123), domain.LoginMethodPhone).
+					Return(service.ErrLastLoginMethod)
+				return usersvc, nil
+			},
+			url:      "/users/unbind",
+			reqBody:  `{"method": "phone"}`,
+			wantBody: Result{Code: 4, Message: "不能解绑最后一种登录方式"},
+		},
+		{
+			name: "未知的登录方式",
+			mock: func(ctrl *gomock.Controller) (service.UserService, service.CodeService) {
+				return svcmocks.NewMockUserService(ctrl), nil
+			},
+			url:      "/users/unbind",
+			reqBody:  `{"method": "qq"}`,
+			wantBody: Result{Code: 4, Message: "未知的登录方式"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			server := gin.Default()
+			server.Use(func(ctx *gin.Context) {
+				ctx.Set(ijwt.ClaimsKey, &ijwt.UserClaims{Uid: 123})
+			})
+			usersvc, codesvc := tc.mock(ctrl)
+			h := NewUserHandler(usersvc, codesvc, nil, nil)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, tc.url, bytes.NewBuffer([]byte(tc.reqBody)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
+func TestUserHandler_SendLoginSmsCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		sendErr  error
+		wantBody Result
+	}{
+		{
+			name:     "发送成功",
+			wantBody: Result{Code: 0, Message: "发送验证码成功"},
+		},
+		{
+			name:     "手机号超过每天的上限",
+			sendErr:  service.ErrCodeSendPhoneLimited,
+			wantBody: Result{Code: 7, Message: "这个手机号今天收到的短信太多了, 请明天再试"},
+		},
+		{
+			name:     "IP 超过上限",
+			sendErr:  service.ErrCodeSendIPLimited,
+			wantBody: Result{Code: 8, Message: "发送短信太多了, 请稍后再试"},
+		},
+		{
+			name:     "需要图形验证码",
+			sendErr:  service.ErrCaptchaRequired,
+			wantBody: Result{Code: 10, Message: "请先完成图形验证码"},
+		},
+		{
+			name:     "图形验证码通行 token 无效",
+			sendErr:  service.ErrCaptchaInvalid,
+			wantBody: Result{Code: 11, Message: "图形验证码无效, 请重新获取"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+			codesvc := svcmocks.NewMockCodeService(ctrl)
+			codesvc.EXPECT().Send(gomock.Any(), "login", "13800000000").
+				DoAndReturn(func(ctx context.Context, biz string, phone string) error {
+					// IP 通过 context 传给限流
+					assert.Equal(t, "10.0.0.1", clientip.FromContext(ctx))
+					return tc.sendErr
+				})
+			server := gin.Default()
+			h := NewUserHandler(nil, codesvc, nil, nil)
+			h.RegisterRoutes(server)
+
+			req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send",
+				bytes.NewBuffer([]byte(`{"phone": "13800000000"}`)))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.RemoteAddr = "10.0.0.1:12345"
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			var res Result
+			err = json.Unmarshal(resp.Body.Bytes(), &res)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantBody, res)
+		})
+	}
+}
+
 func TestMock(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	defer ctrl.Finish()
@@ -1,9 +1,11 @@
 package web
 
 import (
+	"basic_go/webook/internal/domain"
 	"basic_go/webook/internal/service"
 	"basic_go/webook/internal/service/oauth2/wechat"
 	ijwt "basic_go/webook/internal/web/jwt"
+	"basic_go/webook/internal/web/middleware"
 	"errors"
 	"fmt"
 	"github.com/gin-gonic/gin"
@@ -17,25 +19,43 @@ type OAuth2WechatHandler struct {
 	svc     wechat.Service
 	userSvc service.UserService
 	ijwt.Handler
-	stateKey []byte
+	stateKeys *ijwt.KeyRing
 }
 
-func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, hdl ijwt.Handler) *OAuth2WechatHandler {
+func NewOAuth2WechatHandler(svc wechat.Service, userSvc service.UserService, hdl ijwt.Handler,
+	stateKeys *ijwt.KeyRing) *OAuth2WechatHandler {
 	return &OAuth2WechatHandler{
-		svc:      svc,
-		userSvc:  userSvc,
-		stateKey: []byte("uX6}oS1`eP0:jY0-oI9:oE4^wD2;tLs@"),
-		Handler:  hdl,
+		svc:       svc,
+		userSvc:   userSvc,
+		stateKeys: stateKeys,
+		Handler:   hdl,
 	}
 }
 
 func (h *OAuth2WechatHandler) RegisterRoutes(server *gin.Engine) {
 	g := server.Group("/oauth2/wechat")
-	g.GET("/authurl", h.AuthURL)
-	g.Any("/callback", h.Callback)
+	g.GET("/authurl", middleware.Public, h.AuthURL)
+	// 已经登录的用户绑定微信, 回调还是同一个
+	g.GET("/bindurl", h.BindURL)
+	g.Any("/callback", middleware.Public, h.Callback)
 }
 
 func (h *OAuth2WechatHandler) AuthURL(ctx *gin.Context) {
+	h.authURL(ctx, 0)
+}
+
+func (h *OAuth2WechatHandler) BindURL(ctx *gin.Context) {
+	c, ok := ctx.MustGet(ijwt.ClaimsKey).(*ijwt.UserClaims)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+		return
+	}
+	h.authURL(ctx, c.Uid)
+}
+
+// authURL bindUid 不为 0 的时候, 回调里把微信绑定到这个用户上, 而不是登录
+// 回调不带登录态, 所以 bindUid 要放在签了名的 state 里
+func (h *OAuth2WechatHandler) authURL(ctx *gin.Context, bindUid int64) {
 	state := uuid.New().String()
 	url, err := h.svc.AuthURL(ctx, state)
 	if err != nil {
@@ -43,19 +63,21 @@ func (h *OAuth2WechatHandler) AuthURL(ctx *gin.Context) {
 			Code:    1,
 			Message: "构造失败",
 		})
+		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS512, StateClaims{
-		State: state,
+	tokenStr, err := h.stateKeys.Sign(StateClaims{
+		State:   state,
+		BindUid: bindUid,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 3)),
 		},
 	})
-	tokenStr, err := token.SignedString(h.stateKey)
 	if err != nil {
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    1,
 			Message: "系统错误",
 		})
+		return
 	}
 	ctx.SetCookie("jwt-state", tokenStr, 600, "/oauth2/wechat/callback",
 		"", false, true)
@@ -69,12 +91,13 @@ func (h *OAuth2WechatHandler) AuthURL(ctx *gin.Context) {
 
 func (h *OAuth2WechatHandler) Callback(ctx *gin.Context) {
 	code := ctx.Query("code")
-	err := h.verifyState(ctx)
+	sc, err := h.verifyState(ctx)
 	if err != nil {
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    5,
 			Message: "登录失败",
 		})
+		return
 	}
 
 	info, err := h.svc.VerifyCode(ctx, code)
@@ -85,7 +108,18 @@ func (h *OAuth2WechatHandler) Callback(ctx *gin.Context) {
 		})
 		return
 	}
+	if sc.BindUid > 0 {
+		h.bind(ctx, sc.BindUid, info)
+		return
+	}
 	u, err := h.userSvc.FindOrCreateByWechat(ctx, info)
+	if errors.Is(err, service.ErrUserBanned) {
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    6,
+			Message: "账号已被封禁",
+		})
+		return
+	}
 	if err != nil {
 		ctx.JSON(http.StatusOK, &Result{
 			Code:    1,
@@ -93,7 +127,7 @@ func (h *OAuth2WechatHandler) Callback(ctx *gin.Context) {
 		})
 		return
 	}
-	err = h.SetLoginToken(ctx, u.Id)
+	err = h.SetLoginToken(ctx, u.Id, u.Roles)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusUnauthorized)
 		return
@@ -103,27 +137,47 @@ func (h *OAuth2WechatHandler) Callback(ctx *gin.Context) {
 	})
 }
 
-func (h *OAuth2WechatHandler) verifyState(ctx *gin.Context) error {
+func (h *OAuth2WechatHandler) bind(ctx *gin.Context, uid int64, info domain.WechatInfo) {
+	err := h.userSvc.BindWechat(ctx, uid, info)
+	switch {
+	case err == nil:
+		ctx.JSON(http.StatusOK, &Result{
+			Message: "绑定成功",
+		})
+	case errors.Is(err, service.ErrIdentityTaken):
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    4,
+			Message: "这个微信已经绑定了其它账号",
+		})
+	default:
+		ctx.JSON(http.StatusOK, &Result{
+			Code:    1,
+			Message: "系统错误",
+		})
+	}
+}
+
+func (h *OAuth2WechatHandler) verifyState(ctx *gin.Context) (StateClaims, error) {
 	state := ctx.Query("state")
 	ck, err := ctx.Cookie("jwt-state")
 	if err != nil {
-		return fmt.Errorf("拿不到 state cookie %s", err)
+		return StateClaims{}, fmt.Errorf("拿不到 state cookie %s", err)
 	}
 
 	var sc StateClaims
-	tokenStr, err := jwt.ParseWithClaims(ck, &sc, func(token *jwt.Token) (interface{}, error) {
-		return h.stateKey, nil
-	})
+	tokenStr, err := h.stateKeys.Parse(ck, &sc)
 	if err != nil || !tokenStr.Valid {
-		return fmt.Errorf("token-state过期, %s", err)
+		return StateClaims{}, fmt.Errorf("token-state过期, %s", err)
 	}
 	if sc.State != state {
-		return errors.New("state 不同")
+		return StateClaims{}, errors.New("state 不同")
 	}
-	return nil
+	return sc, nil
 }
 
 type StateClaims struct {
 	State string
+	// BindUid 绑定微信的时候是当前登录的用户, 登录的时候是 0
+	BindUid int64
 	jwt.RegisteredClaims
 }
@@ -6,49 +6,103 @@ import (
 	"basic_go/webook/internal/repository/cache"
 	"basic_go/webook/internal/repository/dao"
 	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/service/email"
+	"basic_go/webook/internal/service/email/localemail"
+	"basic_go/webook/internal/service/email/smtp"
 	"basic_go/webook/internal/service/oauth2/wechat"
+	"basic_go/webook/internal/service/sms"
+	"basic_go/webook/internal/service/sms/aliyun"
+	"basic_go/webook/internal/service/sms/async"
+	"basic_go/webook/internal/service/sms/audit"
+	"basic_go/webook/internal/service/sms/failover"
 	"basic_go/webook/internal/service/sms/localsms"
 	"basic_go/webook/internal/service/sms/ratelimit"
+	"basic_go/webook/internal/service/sms/tencent"
 	"basic_go/webook/internal/web"
 	ijwt "basic_go/webook/internal/web/jwt"
 	"basic_go/webook/internal/web/middleware"
 	"basic_go/webook/pkg/limiter"
+	ginratelimit "basic_go/webook/pkg/ratelimit"
+	"basic_go/webook/pkg/route"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/memstore"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common"
+	"github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/common/profile"
+	tencentsms "github.com/tencentcloud/tencentcloud-sdk-go/tencentcloud/sms/v20210111"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"log"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
 	db := initDB()
 	rdb := initRedis()
-	jhd := ijwt.NewRedisJWTHandler(rdb)
-	server := initWebServer(jhd)
-	u := initUser(db, rdb, jhd)
+	accessKeys := initKeyRing(config.Config.JWT.AccessKeys)
+	jhd := ijwt.NewRedisJWTHandler(rdb, accessKeys, initKeyRing(config.Config.JWT.RefreshKeys))
+	server := initWebServer(jhd, rdb)
+	smsSvc := initSMS(db, rdb)
+	captchaSvc := service.NewCaptchaService(repository.NewCaptchaRepository(cache.NewCaptchaCache(rdb)))
+	web.NewCaptchaHandler(captchaSvc).RegisterRoutes(server)
+	u := initUser(db, rdb, jhd, smsSvc, captchaSvc)
 	u.RegisterRoutes(server)
-	whd := initWechat("appid", "appSecrect", db, rdb, jhd)
+	web.NewJWKSHandler(accessKeys).RegisterRoutes(server)
+	whd := initWechat("appid", "appSecrect", db, rdb, jhd, initKeyRing(config.Config.JWT.StateKeys))
 	whd.RegisterRoutes(server)
+	initAdminUser(db, rdb, jhd).RegisterRoutes(server)
+	smsLogSvc := service.NewSMSLogService(repository.NewSMSLogRepository(dao.NewSMSLogDAO(db)))
+	web.NewAdminSMSHandler(smsLogSvc).RegisterRoutes(server)
+	web.NewSMSCallbackHandler(smsLogSvc, config.Config.SMS.CallbackToken).RegisterRoutes(server)
 	//server := gin.Default()
 	server.GET("/hello", func(ctx *gin.Context) {
 		ctx.String(http.StatusOK, "你好，你来了")
 	})
-	server.Run(":8080")
+	run(server, smsSvc)
 }
 
-func initWebServer(jhd ijwt.Handler) *gin.Engine {
-	server := gin.Default()
+// run 收到退出信号之后, 先停掉 HTTP 服务, 再等异步短信的 worker 把手上的那条发完
+func run(server *gin.Engine, smsSvc *async.Service) {
+	smsSvc.Start()
+	srv := &http.Server{Addr: ":8080", Handler: server}
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("关闭 HTTP 服务失败", err)
+	}
+	if err := smsSvc.Close(shutdownCtx); err != nil {
+		log.Println("关闭异步短信失败", err)
+	}
+}
+
+func initWebServer(jhd ijwt.Handler, rdb *redis.Client) *gin.Engine {
+	server := initGinEngine(config.Config.Server)
 	server.Use(cors.New(cors.Config{
 		AllowOrigins: []string{"http://localhost:3000"},
 		AllowMethods: []string{"PUT", "PATCH", "POST", "GET"},
-		AllowHeaders: []string{"Content-Type", "Authorization"},
-		// 让前端拿到token
-		ExposeHeaders:    []string{"x-jwt-token"},
+		AllowHeaders: []string{"Content-Type", "Authorization", "X-Captcha-Token"},
+		// 让前端拿到token, 还有被限流的时候什么时候能重试
+		ExposeHeaders: []string{"x-jwt-token", "x-refresh-token",
+			"X-RateLimit-Limit", "X-RateLimit-Remaining", "Retry-After"},
 		AllowCredentials: true,
 		AllowOriginFunc: func(origin string) bool {
 			//return origin == "https://github.com"
@@ -69,15 +123,102 @@ func initWebServer(jhd ijwt.Handler) *gin.Engine {
 	server.Use(sessions.Sessions("mysession", store))
 	//server.Use(middleware.NewLoginMiddlewareBuilder().IgnorePaths("/users/login").
 	//	IgnorePaths("/users/signup").Build())
-	server.Use(middleware.NewLoginJWTMiddlewareBuilder(jhd).IgnorePaths("/users/login").
-		IgnorePaths("/users/signup").IgnorePaths("/users/login_sms/code/send").
-		IgnorePaths("/users/login_sms").
-		IgnorePaths("/oauth2/wechat/authurl").
-		IgnorePaths("oauth2/wechat/callback").
+	// 不需要登录的路由在各自的 RegisterRoutes 里用 middleware.Public 声明
+	server.Use(middleware.NewLoginJWTMiddlewareBuilder(jhd).
+		Binding(initBindingPolicy(config.Config.JWT.Binding)).
 		Build())
+	// 放在登录校验后面, 才能按 uid 限流
+	server.Use(ginratelimit.NewRulesBuilder(initRateLimitRules(rdb, config.Config.RateLimit)...).Build())
 	return server
 }
 
+// initGinEngine gin 默认信任所有代理, 客户端随便带个 X-Forwarded-For 就能换 IP, 所以要明确设置
+func initGinEngine(cfg config.ServerConfig) *gin.Engine {
+	server := gin.Default()
+	if err := server.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(err)
+	}
+	if len(cfg.RemoteIPHeaders) > 0 {
+		server.RemoteIPHeaders = cfg.RemoteIPHeaders
+	}
+	return server
+}
+
+func initRateLimitRules(rdb *redis.Client, cfgs []config.RateLimitRuleConfig) []ginratelimit.Rule {
+	rules := make([]ginratelimit.Rule, 0, len(cfgs))
+	for _, cfg := range cfgs {
+		r := ginratelimit.Rule{
+			Name:    cfg.Name,
+			Key:     initRateLimitKey(cfg.Key),
+			Limiter: initRateLimiter(rdb, cfg),
+		}
+		for _, p := range cfg.Routes {
+			r.Routes = append(r.Routes, route.MustParseRule(p))
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+func initRateLimitKey(key string) ginratelimit.KeyFunc {
+	switch {
+	case key == "ip":
+		return ginratelimit.KeyByIP()
+	case key == "uid":
+		return ginratelimit.KeyByUid(func(ctx *gin.Context) (int64, bool) {
+			claims, ok := ctx.Get(ijwt.ClaimsKey)
+			if !ok {
+				return 0, false
+			}
+			uc, ok := claims.(*ijwt.UserClaims)
+			if !ok {
+				return 0, false
+			}
+			return uc.Uid, true
+		})
+	case strings.HasPrefix(key, "header:"):
+		return ginratelimit.KeyByHeader(strings.TrimPrefix(key, "header:"))
+	default:
+		panic(fmt.Sprintf("不支持的限流 key %s", key))
+	}
+}
+
+// initRateLimiter Redis 出问题的时候降级到本地限流, 不能因为限流把整个服务拖垮
+func initRateLimiter(rdb *redis.Client, cfg config.RateLimitRuleConfig) limiter.Limiter {
+	if cfg.Window <= 0 || cfg.Threshold <= 0 {
+		panic(fmt.Sprintf("限流规则 %s 的 Window 和 Threshold 都要大于 0", cfg.Name))
+	}
+	var l limiter.Limiter
+	switch cfg.Algorithm {
+	case "sliding_window":
+		l = limiter.NewRedisSlidingWindowLimiter(rdb, cfg.Window, cfg.Threshold)
+	case "fixed_window":
+		l = limiter.NewRedisFixedWindowLimiter(rdb, cfg.Window, cfg.Threshold)
+	case "token_bucket":
+		l = limiter.NewRedisTokenBucketLimiter(rdb, cfg.Threshold, cfg.Window, cfg.Threshold)
+	default:
+		panic(fmt.Sprintf("不支持的限流算法 %s", cfg.Algorithm))
+	}
+	return limiter.NewFallbackLimiter(l, limiter.NewLocalTokenBucketLimiter(cfg.Threshold, cfg.Window, cfg.Threshold))
+}
+
+func initBindingPolicy(cfg config.BindingConfig) middleware.BindingPolicy {
+	uaMode, err := middleware.ParseBindingMode(cfg.UserAgent)
+	if err != nil {
+		panic(err)
+	}
+	ipMode, err := middleware.ParseBindingMode(cfg.IP)
+	if err != nil {
+		panic(err)
+	}
+	return middleware.BindingPolicy{
+		UserAgent:    uaMode,
+		IP:           ipMode,
+		IPv4MaskBits: cfg.IPv4MaskBits,
+		IPv6MaskBits: cfg.IPv6MaskBits,
+	}
+}
+
 func initDB() *gorm.DB {
 	dsn := config.Config.DB.DSN
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
@@ -98,30 +239,154 @@ func initRedis() *redis.Client {
 	return rdb
 }
 
-func initUser(db *gorm.DB, rdb *redis.Client, jhd ijwt.Handler) *web.UserHandler {
+func initKeyRing(cfg config.KeyRingConfig) *ijwt.KeyRing {
+	var (
+		ring *ijwt.KeyRing
+		err  error
+	)
+	switch cfg.Algorithm {
+	case "", "HS512":
+		ring, err = ijwt.NewHMACKeyRing(cfg.Active, cfg.Keys)
+	default:
+		ring, err = ijwt.NewPEMKeyRing(cfg.Active, cfg.Algorithm, cfg.Keys)
+	}
+	if err != nil {
+		panic(err)
+	}
+	return ring
+}
+
+func initUser(db *gorm.DB, rdb *redis.Client, jhd ijwt.Handler, smsSvc sms.Service,
+	captchaSvc service.CaptchaService) *web.UserHandler {
 	ud := dao.NewUserDAO(db)
 	rd := cache.NewUserCache(rdb)
 	repo := repository.NewUserRepository(ud, rd)
 	svc := service.NewUserService(repo)
 	codeCache := cache.NewCodeCache(rdb)
 	codeRepo := repository.NewCodeRepository(codeCache)
-	//smsClient, err := sms.NewClient(common.NewCredential("", ""), "ap-nanjing", profile.NewClientProfile())
-	//if err != nil {
-	//	panic("smsClient初始化失败")
-	//}
-	//smsSvc := tencent.NewService(smsClient, "", "")
-	smsSvc := localsms.NewService()
-	rateSvc := ratelimit.NewRateLimitSMSService(smsSvc, limiter.NewRedisSlidingWindowLimiter(rdb, time.Second, 10))
-	codeSvc := service.NewCodeService(codeRepo, rateSvc)
-	u := web.NewUserHandler(svc, codeSvc, jhd)
+	codeSvc := initSMSCodeService(rdb, config.Config.SMS.Limit, codeRepo, smsSvc, captchaSvc)
+	emailCodeSvc := service.NewCodeService(codeRepo, service.NewEmailCodeSender(initEmail(config.Config.Email)))
+	u := web.NewUserHandler(svc, codeSvc, emailCodeSvc, jhd)
 	return u
 }
 
-func initWechat(appId string, appSecrect string, db *gorm.DB, rdb *redis.Client, jhd ijwt.Handler) *web.OAuth2WechatHandler {
+// initSMSCodeService 同一个 IP 发得多了, 先要过图形验证码
+func initSMSCodeService(rdb redis.Cmdable, cfg config.SMSLimitConfig, codeRepo repository.CodeRepository,
+	smsSvc sms.Service, captchaSvc service.CaptchaService) service.CodeService {
+	codeSvc := service.NewCodeService(codeRepo, service.NewSMSCodeSender(initSMSLimit(rdb, cfg, smsSvc)))
+	if cfg.CaptchaIPPerHour > 0 {
+		codeSvc = service.NewCaptchaCodeService(codeSvc, captchaSvc,
+			limiter.NewRedisSlidingWindowLimiter(rdb, time.Hour, cfg.CaptchaIPPerHour))
+	}
+	return codeSvc
+}
+
+// initSMS 短信都存到数据库里由后台发送, 服务商失败或者触发全局限流的等着重试
+func initSMS(db *gorm.DB, rdb redis.Cmdable) *async.Service {
+	smsSvc := initSMSFailover(config.Config.SMS, repository.NewSMSLogRepository(dao.NewSMSLogDAO(db)))
+	repo := repository.NewAsyncSMSRepository(dao.NewAsyncSMSDAO(db))
+	return async.NewService(initSMSGlobalLimit(rdb, config.Config.SMS.Limit, smsSvc), repo, 3)
+}
+
+// initSMSGlobalLimit 全局额度是保护服务商账号的, 套在异步发送里面, 超了就排队等着
+func initSMSGlobalLimit(rdb redis.Cmdable, cfg config.SMSLimitConfig, smsSvc sms.Service) sms.Service {
+	if cfg.GlobalPerSecond <= 0 {
+		return smsSvc
+	}
+	return ratelimit.NewRateLimitSMSService(smsSvc, limiter.NewRedisSlidingWindowLimiter(rdb, time.Second, cfg.GlobalPerSecond))
+}
+
+// initSMSFailover 一个服务商出问题不影响用户登录
+// 每个服务商单独套一层记录, 才能按服务商统计失败率
+func initSMSFailover(cfg config.SMSConfig, logRepo repository.SMSLogRepository) sms.Service {
+	providers := cfg.Providers
+	if len(providers) == 0 {
+		providers = []string{"local"}
+	}
+	svcs := make([]sms.Service, 0, len(providers))
+	for _, provider := range providers {
+		svcs = append(svcs, audit.NewService(initSMSProvider(cfg, provider), provider, logRepo))
+	}
+	switch cfg.Failover {
+	case "", "round_robin":
+		svc := failover.NewFailoverSMSService(svcs)
+		if cfg.Timeout > 0 {
+			svc.Timeout(cfg.Timeout)
+		}
+		return svc
+	case "timeout":
+		svc := failover.NewTimeoutFailoverSMSService(svcs, cfg.TimeoutThreshold)
+		if cfg.Timeout > 0 {
+			svc.Timeout(cfg.Timeout)
+		}
+		return svc
+	default:
+		panic(fmt.Sprintf("不支持的短信切换策略 %s", cfg.Failover))
+	}
+}
+
+// initSMSLimit 按手机号和 IP 限流是防刷的, 套在异步发送的外面, 被拒绝了直接告诉用户, 不会排队
+func initSMSLimit(rdb redis.Cmdable, cfg config.SMSLimitConfig, smsSvc sms.Service) sms.Service {
+	svc := ratelimit.NewKeyedRateLimitSMSService(smsSvc)
+	if cfg.PhonePerDay > 0 {
+		svc.PhoneLimiter(limiter.NewRedisSlidingWindowLimiter(rdb, time.Hour*24, cfg.PhonePerDay))
+	}
+	if cfg.IPPerHour > 0 {
+		svc.IPLimiter(limiter.NewRedisSlidingWindowLimiter(rdb, time.Hour, cfg.IPPerHour))
+	}
+	return svc
+}
+
+// initSMSProvider 按照名字创建短信服务商
+func initSMSProvider(cfg config.SMSConfig, provider string) sms.Service {
+	switch provider {
+	case "tencent":
+		client, err := tencentsms.NewClient(common.NewCredential(cfg.Tencent.SecretId, cfg.Tencent.SecretKey),
+			cfg.Tencent.Region, profile.NewClientProfile())
+		if err != nil {
+			panic(err)
+		}
+		return tencent.NewService(client, cfg.Tencent.AppId, cfg.Tencent.SignName, cfg.Tencent.Templates)
+	case "aliyun":
+		templates := make(map[string]aliyun.Template, len(cfg.Aliyun.Templates))
+		for tplId, tpl := range cfg.Aliyun.Templates {
+			templates[tplId] = aliyun.Template{Code: tpl.Code, Params: tpl.Params}
+		}
+		return aliyun.NewService(&http.Client{Timeout: time.Second * 5}, cfg.Aliyun.Endpoint,
+			cfg.Aliyun.AccessKeyId, cfg.Aliyun.AccessKeySecret, cfg.Aliyun.SignName, templates)
+	case "local":
+		return localsms.NewService()
+	default:
+		panic(fmt.Sprintf("不支持的短信服务商 %s", provider))
+	}
+}
+
+// initEmail 没有配置 SMTP 的时候邮件只打日志
+func initEmail(cfg config.EmailConfig) email.Service {
+	if cfg.Addr == "" {
+		return localemail.NewService()
+	}
+	svc, err := smtp.NewService(cfg.Addr, cfg.Username, cfg.Password, cfg.From)
+	if err != nil {
+		panic(err)
+	}
+	return svc
+}
+
+func initWechat(appId string, appSecrect string, db *gorm.DB, rdb *redis.Client, jhd ijwt.Handler,
+	stateKeys *ijwt.KeyRing) *web.OAuth2WechatHandler {
 	svc := wechat.NewService(appId, appSecrect)
 	ud := dao.NewUserDAO(db)
 	rd := cache.NewUserCache(rdb)
 	repo := repository.NewUserRepository(ud, rd)
 	userSvc := service.NewUserService(repo)
-	return web.NewOAuth2WechatHandler(svc, userSvc, jhd)
+	return web.NewOAuth2WechatHandler(svc, userSvc, jhd, stateKeys)
+}
+
+func initAdminUser(db *gorm.DB, rdb *redis.Client, jhd ijwt.Handler) *web.AdminUserHandler {
+	ud := dao.NewUserDAO(db)
+	rd := cache.NewUserCache(rdb)
+	repo := repository.NewUserRepository(ud, rd)
+	userSvc := service.NewUserService(repo)
+	return web.NewAdminUserHandler(userSvc, jhd)
 }
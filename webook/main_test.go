@@ -0,0 +1,252 @@
+package main
+
+import (
+	"basic_go/webook/config"
+	"basic_go/webook/internal/repository"
+	"basic_go/webook/internal/repository/cache"
+	"basic_go/webook/internal/repository/dao"
+	"basic_go/webook/internal/repository/dao/daotest"
+	"basic_go/webook/internal/service"
+	"basic_go/webook/internal/service/sms/async"
+	"basic_go/webook/internal/web"
+	ginratelimit "basic_go/webook/pkg/ratelimit"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMSService 服务商, err 不为空的时候发送失败
+type fakeSMSService struct {
+	mu    sync.Mutex
+	err   error
+	calls int
+}
+
+func (f *fakeSMSService) Send(ctx context.Context, tplId string, args []string, numbers ...string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	return f.err
+}
+
+func (f *fakeSMSService) Calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls
+}
+
+// TestSMSCodeChain 按照 main 里面的组装方式, 从接口一直到服务商
+func TestSMSCodeChain(t *testing.T) {
+	testCases := []struct {
+		name        string
+		limit       config.SMSLimitConfig
+		providerErr error
+		// phones 依次给这些手机号发验证码, 每次都换一个 X-Forwarded-For
+		phones    []string
+		wantCodes []int
+		wantCalls int
+		// wantSuccess 发送成功的条数, wantRetry 失败了排队等着重试的条数
+		wantSuccess int64
+		wantRetry   int64
+	}{
+		{
+			name:        "全局额度用完, 排队等着发",
+			limit:       config.SMSLimitConfig{GlobalPerSecond: 2},
+			phones:      []string{"13800000001", "13800000002", "13800000003"},
+			wantCodes:   []int{0, 0, 0},
+			wantCalls:   2,
+			wantSuccess: 2,
+			wantRetry:   1,
+		},
+		{
+			name:        "服务商失败, 排队重试",
+			limit:       config.SMSLimitConfig{GlobalPerSecond: 2},
+			providerErr: errors.New("服务商出错"),
+			phones:      []string{"13800000001"},
+			wantCodes:   []int{0},
+			wantCalls:   1,
+			wantRetry:   1,
+		},
+		{
+			name:        "换 X-Forwarded-For 绕不过 IP 限流",
+			limit:       config.SMSLimitConfig{IPPerHour: 2},
+			phones:      []string{"13800000001", "13800000002", "13800000003"},
+			wantCodes:   []int{0, 0, 8},
+			wantCalls:   2,
+			wantSuccess: 2,
+		},
+		{
+			name:        "换 X-Forwarded-For 绕不过图形验证码",
+			limit:       config.SMSLimitConfig{CaptchaIPPerHour: 2},
+			phones:      []string{"13800000001", "13800000002", "13800000003"},
+			wantCodes:   []int{0, 0, 10},
+			wantCalls:   2,
+			wantSuccess: 2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			db := initTestDB(t)
+
+			provider := &fakeSMSService{err: tc.providerErr}
+			smsSvc := async.NewService(initSMSGlobalLimit(rdb, tc.limit, provider),
+				repository.NewAsyncSMSRepository(dao.NewAsyncSMSDAO(db)), 3)
+			captchaSvc := service.NewCaptchaService(repository.NewCaptchaRepository(cache.NewCaptchaCache(rdb)))
+			codeSvc := initSMSCodeService(rdb, tc.limit, repository.NewCodeRepository(cache.NewCodeCache(rdb)),
+				smsSvc, captchaSvc)
+			u := web.NewUserHandler(nil, codeSvc, nil, nil)
+			server := initGinEngine(config.ServerConfig{})
+			server.POST("/users/login_sms/code/send", u.SendLoginSmsCode)
+
+			for i, phone := range tc.phones {
+				req, err := http.NewRequest(http.MethodPost, "/users/login_sms/code/send",
+					bytes.NewBufferString(`{"phone":"`+phone+`"}`))
+				require.NoError(t, err)
+				req.Header.Set("Content-Type", "application/json")
+				req.RemoteAddr = "203.0.113.7:5678"
+				req.Header.Set("X-Forwarded-For", fmt.Sprintf("1.2.3.%d", i))
+				resp := httptest.NewRecorder()
+				server.ServeHTTP(resp, req)
+
+				var res web.Result
+				require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+				assert.Equal(t, tc.wantCodes[i], res.Code, phone)
+				// 没发出去的验证码不留在 redis 里, 不占着重发间隔
+				assert.Equal(t, res.Code == 0, mr.Exists("phone_code:login:"+phone), phone)
+			}
+			// 接口返回的时候还没有调用服务商
+			assert.Zero(t, provider.Calls())
+
+			smsSvc.Start()
+			defer func() {
+				assert.NoError(t, smsSvc.Close(context.Background()))
+			}()
+			// 失败之后第一次重试要等 10 秒, 测试结束之前不会再发
+			require.Eventually(t, func() bool {
+				var success, retry int64
+				err1 := db.Model(&dao.AsyncSMS{}).
+					Where("status = ?", dao.AsyncSMSStatusSuccess).Count(&success).Error
+				err2 := db.Model(&dao.AsyncSMS{}).
+					Where("status = ? AND retry_cnt = 1", dao.AsyncSMSStatusWaiting).Count(&retry).Error
+				return err1 == nil && err2 == nil && success == tc.wantSuccess && retry == tc.wantRetry
+			}, time.Second*5, time.Millisecond*10)
+			assert.Equal(t, tc.wantCalls, provider.Calls())
+		})
+	}
+}
+
+func TestInitGinEngine_ClientIP(t *testing.T) {
+	testCases := []struct {
+		name       string
+		cfg        config.ServerConfig
+		remoteAddr string
+		xff        string
+		wantIP     string
+	}{
+		{
+			name:       "没有配置代理, 不认客户端带的头",
+			remoteAddr: "203.0.113.7:5678",
+			xff:        "1.2.3.4",
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "可信的代理转发过来的",
+			cfg:        config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.1.2.3:5678",
+			xff:        "203.0.113.7",
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "客户端自己伪造的部分不算",
+			cfg:        config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "10.1.2.3:5678",
+			xff:        "1.2.3.4, 203.0.113.7",
+			wantIP:     "203.0.113.7",
+		},
+		{
+			name:       "不是从代理来的",
+			cfg:        config.ServerConfig{TrustedProxies: []string{"10.0.0.0/8"}},
+			remoteAddr: "203.0.113.7:5678",
+			xff:        "1.2.3.4",
+			wantIP:     "203.0.113.7",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := initGinEngine(tc.cfg)
+			server.GET("/ip", func(ctx *gin.Context) {
+				ctx.String(http.StatusOK, ctx.ClientIP())
+			})
+			req, err := http.NewRequest(http.MethodGet, "/ip", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tc.remoteAddr
+			req.Header.Set("X-Forwarded-For", tc.xff)
+			resp := httptest.NewRecorder()
+			server.ServeHTTP(resp, req)
+			assert.Equal(t, tc.wantIP, resp.Body.String())
+		})
+	}
+}
+
+// TestRateLimitRules_ForwardedFor 用配置里的规则, 换 X-Forwarded-For 也逃不过按 IP 的登录限流
+func TestRateLimitRules_ForwardedFor(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	server := initGinEngine(config.ServerConfig{})
+	server.Use(ginratelimit.NewRulesBuilder(initRateLimitRules(rdb, config.Config.RateLimit)...).Build())
+	server.POST("/users/login", func(ctx *gin.Context) {
+		ctx.Status(http.StatusOK)
+	})
+
+	// 配置里登录是一分钟 10 次
+	codes := make([]int, 0, 11)
+	for i := 0; i < 11; i++ {
+		req, err := http.NewRequest(http.MethodPost, "/users/login", nil)
+		require.NoError(t, err)
+		req.RemoteAddr = "203.0.113.7:5678"
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("1.2.3.%d", i))
+		resp := httptest.NewRecorder()
+		server.ServeHTTP(resp, req)
+		codes = append(codes, resp.Code)
+	}
+	assert.Equal(t, http.StatusTooManyRequests, codes[10])
+	assert.NotContains(t, codes[:10], http.StatusTooManyRequests)
+}
+
+func TestInitRateLimiter_Invalid(t *testing.T) {
+	assert.PanicsWithValue(t, "限流规则 login 的 Window 和 Threshold 都要大于 0", func() {
+		initRateLimiter(nil, config.RateLimitRuleConfig{
+			Name:      "login",
+			Algorithm: "token_bucket",
+			Window:    time.Minute,
+		})
+	})
+	assert.PanicsWithValue(t, "限流规则 global 的 Window 和 Threshold 都要大于 0", func() {
+		initRateLimiter(nil, config.RateLimitRuleConfig{
+			Name:      "global",
+			Algorithm: "sliding_window",
+			Threshold: 10,
+		})
+	})
+}
+
+func initTestDB(t *testing.T) *gorm.DB {
+	db := daotest.NewMemoryDB(t)
+	require.NoError(t, dao.InitTable(db))
+	return db
+}
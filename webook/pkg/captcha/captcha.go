@@ -0,0 +1,123 @@
+// Package captcha 生成图形验证码的题目和图片, 不关心答案存在哪里
+package captcha
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"strconv"
+)
+
+// RandomDigits n 位随机数字, 题目和答案是同一个
+func RandomDigits(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte('0' + rand.Intn(10))
+	}
+	return string(b)
+}
+
+// RandomMath 20 以内的加减法, 结果不会是负数
+func RandomMath() (question string, answer string) {
+	a, b := rand.Intn(20)+1, rand.Intn(20)+1
+	if rand.Intn(2) == 0 {
+		return fmt.Sprintf("%d+%d=?", a, b), strconv.Itoa(a + b)
+	}
+	if a < b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%d-%d=?", a, b), strconv.Itoa(a - b)
+}
+
+// font 5x7 的点阵字体, 只有题目里会出现的字符
+var font = map[rune][7]string{
+	'0': {" ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### "},
+	'1': {"  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### "},
+	'2': {" ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####"},
+	'3': {"#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### "},
+	'4': {"   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # "},
+	'5': {"#####", "#    ", "#### ", "    #", "    #", "#   #", " ### "},
+	'6': {"  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### "},
+	'7': {"#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   "},
+	'8': {" ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### "},
+	'9': {" ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  "},
+	'+': {"     ", "  #  ", "  #  ", "#####", "  #  ", "  #  ", "     "},
+	'-': {"     ", "     ", "     ", "#####", "     ", "     ", "     "},
+	'=': {"     ", "     ", "#####", "     ", "#####", "     ", "     "},
+	'?': {" ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  "},
+}
+
+const (
+	scale   = 4
+	charW   = 5*scale + scale*2
+	padding = 10
+	height  = 7*scale + padding*2
+)
+
+// Render 把题目画成 PNG, 每个字符上下随机错开, 再加上干扰点和干扰线
+func Render(text string) ([]byte, error) {
+	runes := []rune(text)
+	width := len(runes)*charW + padding*2
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	bg := color.RGBA{R: 240, G: 240, B: 240, A: 255}
+	for x := 0; x < width; x++ {
+		for y := 0; y < height; y++ {
+			img.Set(x, y, bg)
+		}
+	}
+	for i, r := range runes {
+		glyph, ok := font[r]
+		if !ok {
+			return nil, fmt.Errorf("不支持的字符 %q", r)
+		}
+		fg := randomColor()
+		x0 := padding + i*charW + rand.Intn(scale)
+		y0 := padding + rand.Intn(padding) - padding/2
+		for row, line := range glyph {
+			for col, c := range line {
+				if c != '#' {
+					continue
+				}
+				fillRect(img, x0+col*scale, y0+row*scale, scale, fg)
+			}
+		}
+	}
+	for i := 0; i < width*height/20; i++ {
+		img.Set(rand.Intn(width), rand.Intn(height), randomColor())
+	}
+	for i := 0; i < 3; i++ {
+		drawLine(img, 0, rand.Intn(height), width, rand.Intn(height), randomColor())
+	}
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+func randomColor() color.RGBA {
+	return color.RGBA{
+		R: uint8(rand.Intn(150)),
+		G: uint8(rand.Intn(150)),
+		B: uint8(rand.Intn(150)),
+		A: 255,
+	}
+}
+
+func fillRect(img *image.RGBA, x, y, size int, c color.Color) {
+	for dx := 0; dx < size; dx++ {
+		for dy := 0; dy < size; dy++ {
+			img.Set(x+dx, y+dy, c)
+		}
+	}
+}
+
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.Color) {
+	steps := x1 - x0
+	for i := 0; i <= steps; i++ {
+		x := x0 + i
+		y := y0 + (y1-y0)*i/steps
+		img.Set(x, y, c)
+	}
+}
@@ -0,0 +1,49 @@
+package captcha
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"image/png"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRandomMath(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		question, answer := RandomMath()
+		expr := strings.TrimSuffix(question, "=?")
+		var a, b int
+		var op string
+		if strings.Contains(expr, "+") {
+			op = "+"
+		} else {
+			op = "-"
+		}
+		parts := strings.Split(expr, op)
+		require.Len(t, parts, 2)
+		a, _ = strconv.Atoi(parts[0])
+		b, _ = strconv.Atoi(parts[1])
+		want := a + b
+		if op == "-" {
+			want = a - b
+		}
+		assert.GreaterOrEqual(t, want, 0)
+		assert.Equal(t, strconv.Itoa(want), answer)
+	}
+}
+
+func TestRender(t *testing.T) {
+	data, err := Render(RandomDigits(4))
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 4*charW+padding*2, img.Bounds().Dx())
+	assert.Equal(t, height, img.Bounds().Dy())
+
+	_, err = Render("12+7=?")
+	assert.NoError(t, err)
+	_, err = Render("abc")
+	assert.Error(t, err)
+}
@@ -0,0 +1,16 @@
+// Package clientip 把客户端 IP 放进 context 里, 让 service 层不依赖 HTTP 也能拿到
+package clientip
+
+import "context"
+
+type ctxKey struct{}
+
+func WithIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, ip)
+}
+
+// FromContext 没有设置过就返回空字符串
+func FromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(ctxKey{}).(string)
+	return ip
+}
@@ -0,0 +1,83 @@
+package limiter
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackLimiter 主限流器(一般是 Redis)出错或者太慢的时候降级:
+// fail-open 交给本地限流器, 没有本地限流器就直接放行; fail-closed 直接拒绝
+// 降级之后的 cooldown 时间内不再访问主限流器, 免得每个请求都要等超时
+type FallbackLimiter struct {
+	primary  Limiter
+	local    Limiter
+	failOpen bool
+	timeout  time.Duration
+	cooldown time.Duration
+	// degradedUntil 降级结束的时间, UnixNano
+	degradedUntil atomic.Int64
+	now           func() time.Time
+}
+
+// NewFallbackLimiter local 可以是 nil, 默认 fail-open
+func NewFallbackLimiter(primary Limiter, local Limiter) *FallbackLimiter {
+	return &FallbackLimiter{
+		primary:  primary,
+		local:    local,
+		failOpen: true,
+		timeout:  time.Millisecond * 100,
+		cooldown: time.Second,
+		now:      time.Now,
+	}
+}
+
+// FailClosed 降级的时候拒绝所有请求, 适合发短信这种放过去就要花钱的场景
+func (f *FallbackLimiter) FailClosed() *FallbackLimiter {
+	f.failOpen = false
+	return f
+}
+
+// Timeout 主限流器超过这个时间没有返回就当作出错
+func (f *FallbackLimiter) Timeout(timeout time.Duration) *FallbackLimiter {
+	f.timeout = timeout
+	return f
+}
+
+// Cooldown 出错之后多久再重新试主限流器
+func (f *FallbackLimiter) Cooldown(cooldown time.Duration) *FallbackLimiter {
+	f.cooldown = cooldown
+	return f
+}
+
+func (f *FallbackLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	if f.now().UnixNano() < f.degradedUntil.Load() {
+		return f.degrade(ctx, key)
+	}
+	pctx, cancel := context.WithTimeout(ctx, f.timeout)
+	res, err := f.primary.Limit(pctx, key)
+	cancel()
+	if err == nil {
+		return res, nil
+	}
+	// 调用方自己取消的, 不是主限流器的问题
+	if ctx.Err() != nil {
+		return Result{}, ctx.Err()
+	}
+	log.Println("限流器出错, 降级", err)
+	f.degradedUntil.Store(f.now().Add(f.cooldown).UnixNano())
+	return f.degrade(ctx, key)
+}
+
+func (f *FallbackLimiter) degrade(ctx context.Context, key string) (Result, error) {
+	if !f.failOpen {
+		// 降级结束之后才可能放行
+		reset := time.Duration(f.degradedUntil.Load() - f.now().UnixNano())
+		return Result{Limited: true, Reset: max(reset, 0)}, nil
+	}
+	if f.local == nil {
+		return Result{}, nil
+	}
+	return f.local.Limit(ctx, key)
+}
@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowLimiter 一直等到超时
+type slowLimiter struct {
+	calls atomic.Int64
+}
+
+func (l *slowLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	l.calls.Add(1)
+	<-ctx.Done()
+	return Result{}, ctx.Err()
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	now := time.UnixMilli(1_000_000)
+	f := NewFallbackLimiter(NewRedisFixedWindowLimiter(rdb, time.Minute, 3),
+		NewLocalTokenBucketLimiter(1, time.Minute, 1)).Cooldown(time.Second)
+	f.now = func() time.Time {
+		return now
+	}
+
+	// Redis 正常的时候用 Redis 的结果
+	assert.Equal(t, 1, limitN(t, f, "key", 4))
+	// Redis 挂了, 降级到本地, 本地只允许 1 个
+	mr.Close()
+	assert.Equal(t, 2, limitN(t, f, "key", 3))
+	// cooldown 之后重新试 Redis, 本地的令牌已经用完了, 能通过就说明用的是 Redis
+	require.NoError(t, mr.Restart())
+	mr.FlushAll()
+	now = now.Add(time.Second)
+	assert.Equal(t, 0, limitN(t, f, "key", 3))
+}
+
+func TestFallbackLimiter_Slow(t *testing.T) {
+	primary := &slowLimiter{}
+	f := NewFallbackLimiter(primary, nil).Timeout(time.Millisecond * 10)
+	// 没有本地限流器, fail-open 直接放行
+	assert.Equal(t, 0, limitN(t, f, "key", 5))
+	// 第一次超时之后 cooldown 内不再访问主限流器
+	assert.Equal(t, int64(1), primary.calls.Load())
+}
+
+func TestFallbackLimiter_FailClosed(t *testing.T) {
+	f := NewFallbackLimiter(&slowLimiter{}, NewLocalTokenBucketLimiter(10, time.Minute, 10)).
+		Timeout(time.Millisecond * 10).FailClosed()
+	now := time.UnixMilli(1_000_000)
+	f.now = func() time.Time {
+		return now
+	}
+	assert.Equal(t, 3, limitN(t, f, "key", 3))
+	// 降级结束之后才可能放行
+	now = now.Add(time.Millisecond * 400)
+	res, err := f.Limit(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, Result{Limited: true, Reset: time.Millisecond * 600}, res)
+}
+
+func TestFallbackLimiter_Canceled(t *testing.T) {
+	primary := &slowLimiter{}
+	f := NewFallbackLimiter(primary, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := f.Limit(ctx, "key")
+	assert.Equal(t, context.Canceled, err)
+	// 调用方取消的不算主限流器出错
+	_, _ = f.Limit(ctx, "key")
+	assert.Equal(t, int64(2), primary.calls.Load())
+}
@@ -0,0 +1,20 @@
+-- 固定窗口, 一个窗口一个计数器, 窗口从第一个请求开始算
+local key = KEYS[1]
+-- 窗口大小, 毫秒
+local window = tonumber(ARGV[1])
+-- 阈值
+local threshold = tonumber(ARGV[2])
+
+local cnt = redis.call('INCR', key)
+local ttl = redis.call('PTTL', key)
+-- 第一个请求设置过期时间, 也防止之前设置过期时间失败的 key 永远不过期
+if cnt == 1 or ttl < 0 then
+    redis.call('PEXPIRE', key, window)
+    ttl = window
+end
+local limited = 0
+if cnt > threshold then
+    limited = 1
+end
+-- 窗口结束的时候额度全部恢复
+return {limited, math.max(0, threshold - cnt), ttl}
@@ -0,0 +1,106 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+	"time"
+)
+
+const localShardCnt = 32
+
+// LocalTokenBucketLimiter 进程内的令牌桶, 只限制本实例, 一般用来给 Redis 兜底
+// key 按哈希分到不同的分片上, 减少锁竞争; 补满了的桶和不存在是一样的, 所以空闲超过补满时间的桶会被清理掉
+type LocalTokenBucketLimiter struct {
+	shards   [localShardCnt]*localShard
+	capacity float64
+	// rate 每纳秒补充的令牌数
+	rate float64
+	// ttl 空桶补满需要的时间, 空闲超过这个时间的桶会被清理
+	ttl time.Duration
+	now func() time.Time
+}
+
+type localShard struct {
+	mu        sync.Mutex
+	buckets   map[string]*localBucket
+	lastSweep time.Time
+}
+
+type localBucket struct {
+	tokens float64
+	ts     time.Time
+}
+
+// NewLocalTokenBucketLimiter 每 interval 补充 rate 个令牌, 桶最多放 capacity 个, 参数不是正数会 panic
+func NewLocalTokenBucketLimiter(capacity int, interval time.Duration, rate int) *LocalTokenBucketLimiter {
+	mustValidTokenBucket(capacity, interval, rate)
+	l := &LocalTokenBucketLimiter{
+		capacity: float64(capacity),
+		rate:     float64(rate) / float64(interval),
+		ttl:      interval * time.Duration(capacity) / time.Duration(rate),
+		now:      time.Now,
+	}
+	for i := range l.shards {
+		l.shards[i] = &localShard{
+			buckets: make(map[string]*localBucket),
+		}
+	}
+	return l
+}
+
+func (l *LocalTokenBucketLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	s := l.shards[h.Sum32()%localShardCnt]
+	now := l.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= l.ttl {
+		l.sweep(s, now)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &localBucket{tokens: l.capacity, ts: now}
+		s.buckets[key] = b
+	}
+	if elapsed := now.Sub(b.ts); elapsed > 0 {
+		b.tokens = min(l.capacity, b.tokens+float64(elapsed)*l.rate)
+		b.ts = now
+	}
+	res := Result{
+		Limited: b.tokens < 1,
+		Limit:   int(l.capacity),
+	}
+	if !res.Limited {
+		b.tokens--
+	}
+	res.Remaining = int(b.tokens)
+	if b.tokens < l.capacity {
+		// 补出下一个整的令牌要多久
+		res.Reset = time.Duration(math.Ceil((math.Floor(b.tokens) + 1 - b.tokens) / l.rate))
+	}
+	return res, nil
+}
+
+// sweep 调用方要持有 s.mu
+func (l *LocalTokenBucketLimiter) sweep(s *localShard, now time.Time) {
+	for key, b := range s.buckets {
+		if now.Sub(b.ts) >= l.ttl {
+			delete(s.buckets, key)
+		}
+	}
+	s.lastSweep = now
+}
+
+// mustValidTokenBucket 这几个参数不是正数的话, 补充速率会算出 0 或者除以 0,
+// 是配置写错了, 和 time.NewTicker 一样直接 panic
+func mustValidTokenBucket(capacity int, interval time.Duration, rate int) {
+	if capacity <= 0 || interval <= 0 || rate <= 0 {
+		panic(fmt.Sprintf("limiter: 令牌桶的 capacity, interval 和 rate 都要大于 0, 实际是 %d, %s, %d",
+			capacity, interval, rate))
+	}
+}
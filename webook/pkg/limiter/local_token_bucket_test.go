@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLocalTokenBucketLimiter(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	// 每秒补 2 个, 最多攒 5 个
+	l := NewLocalTokenBucketLimiter(5, time.Second, 2)
+	l.now = func() time.Time {
+		return now
+	}
+
+	assert.Equal(t, 1, limitN(t, l, "tb", 6))
+	now = now.Add(time.Millisecond * 500)
+	assert.Equal(t, 1, limitN(t, l, "tb", 2))
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2, limitN(t, l, "tb", 7))
+	assert.Equal(t, 0, limitN(t, l, "other", 5))
+}
+
+func TestNewTokenBucketLimiter_Invalid(t *testing.T) {
+	testCases := []struct {
+		name     string
+		capacity int
+		interval time.Duration
+		rate     int
+	}{
+		{name: "rate 是 0", capacity: 5, interval: time.Second, rate: 0},
+		{name: "capacity 是 0", capacity: 0, interval: time.Second, rate: 2},
+		{name: "interval 是 0", capacity: 5, interval: 0, rate: 2},
+		{name: "负数", capacity: -1, interval: time.Second, rate: 2},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Panics(t, func() {
+				NewLocalTokenBucketLimiter(tc.capacity, tc.interval, tc.rate)
+			})
+			assert.Panics(t, func() {
+				NewRedisTokenBucketLimiter(nil, tc.capacity, tc.interval, tc.rate)
+			})
+		})
+	}
+	// Redis 里按毫秒算, 不到 1 毫秒也不行
+	assert.Panics(t, func() {
+		NewRedisTokenBucketLimiter(nil, 5, time.Microsecond*500, 2)
+	})
+	assert.NotPanics(t, func() {
+		NewLocalTokenBucketLimiter(5, time.Microsecond*500, 2)
+	})
+}
+
+func TestLocalTokenBucketLimiter_Evict(t *testing.T) {
+	now := time.UnixMilli(1_000_000)
+	l := NewLocalTokenBucketLimiter(5, time.Second, 2)
+	l.now = func() time.Time {
+		return now
+	}
+	for i := 0; i < 1000; i++ {
+		limitN(t, l, fmt.Sprintf("key-%d", i), 1)
+	}
+	// 2.5 秒之后都补满了, 下一次访问对应分片的时候清理掉
+	now = now.Add(time.Millisecond * 2500)
+	for i := 0; i < 1000; i++ {
+		limitN(t, l, fmt.Sprintf("new-%d", i), 1)
+	}
+	total := 0
+	for _, s := range l.shards {
+		for key := range s.buckets {
+			assert.Contains(t, key, "new-")
+			total++
+		}
+	}
+	assert.Equal(t, 1000, total)
+}
+
+func TestLocalTokenBucketLimiter_Concurrent(t *testing.T) {
+	// 补充得很慢, 测试期间相当于只有 capacity 个令牌
+	l := NewLocalTokenBucketLimiter(100, time.Hour, 1)
+	var passed atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				res, _ := l.Limit(context.Background(), "tb")
+				if !res.Limited {
+					passed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(100), passed.Load())
+}
+
+func TestLocalTokenBucketLimiter_Result(t *testing.T) {
+	l := NewLocalTokenBucketLimiter(2, time.Second, 2)
+	now := time.UnixMilli(1_000_000)
+	l.now = func() time.Time {
+		return now
+	}
+	want := []Result{
+		{Limit: 2, Remaining: 1, Reset: time.Millisecond * 500},
+		{Limit: 2, Remaining: 0, Reset: time.Millisecond * 500},
+		{Limited: true, Limit: 2, Remaining: 0, Reset: time.Millisecond * 500},
+	}
+	for _, w := range want {
+		res, err := l.Limit(context.Background(), "tb")
+		assert.NoError(t, err)
+		assert.Equal(t, w, res)
+	}
+	// 过了 200 毫秒, 再等 300 毫秒就有一个令牌
+	now = now.Add(time.Millisecond * 200)
+	res, err := l.Limit(context.Background(), "tb")
+	assert.NoError(t, err)
+	assert.Equal(t, Result{Limited: true, Limit: 2, Remaining: 0, Reset: time.Millisecond * 300}, res)
+}
@@ -0,0 +1,35 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed fixed_window.lua
+var luaFixedWindow string
+
+// RedisFixedWindowLimiter 固定窗口, 每个 key 只有一个计数器,
+// 但是窗口交界的地方最多可能放过 2 * rate 个请求
+type RedisFixedWindowLimiter struct {
+	cmd      redis.Cmdable
+	interval time.Duration
+	rate     int
+}
+
+func NewRedisFixedWindowLimiter(cmd redis.Cmdable, interval time.Duration, rate int) *RedisFixedWindowLimiter {
+	return &RedisFixedWindowLimiter{
+		cmd:      cmd,
+		interval: interval,
+		rate:     rate,
+	}
+}
+
+func (b *RedisFixedWindowLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	vals, err := b.cmd.Eval(ctx, luaFixedWindow, []string{key}, b.interval.Milliseconds(), b.rate).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return luaResult(vals, b.rate), nil
+}
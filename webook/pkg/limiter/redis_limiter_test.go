@@ -0,0 +1,191 @@
+package limiter
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, redis.Cmdable) {
+	mr := miniredis.RunT(t)
+	return mr, redis.NewClient(&redis.Options{Addr: mr.Addr()})
+}
+
+// limitN 连续请求 n 次, 返回被限流的次数
+func limitN(t *testing.T, l Limiter, key string, n int) int {
+	cnt := 0
+	for i := 0; i < n; i++ {
+		res, err := l.Limit(context.Background(), key)
+		require.NoError(t, err)
+		if res.Limited {
+			cnt++
+		}
+	}
+	return cnt
+}
+
+func TestRedisTokenBucketLimiter(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	now := time.UnixMilli(1_000_000)
+	// 每秒补 2 个, 最多攒 5 个
+	l := NewRedisTokenBucketLimiter(rdb, 5, time.Second, 2)
+	l.now = func() time.Time {
+		return now
+	}
+
+	// 一开始桶是满的, 允许突发 5 个
+	assert.Equal(t, 1, limitN(t, l, "tb", 6))
+	// 过了半秒补 1 个
+	now = now.Add(time.Millisecond * 500)
+	assert.Equal(t, 1, limitN(t, l, "tb", 2))
+	// 过了很久也最多补满 5 个
+	now = now.Add(time.Minute)
+	assert.Equal(t, 2, limitN(t, l, "tb", 7))
+	// 不同的 key 互不影响
+	assert.Equal(t, 0, limitN(t, l, "other", 5))
+}
+
+func TestRedisTokenBucketLimiter_Expire(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	l := NewRedisTokenBucketLimiter(rdb, 5, time.Second, 2)
+	_, err := l.Limit(context.Background(), "tb")
+	require.NoError(t, err)
+	// 2.5 秒就补满了, 之后 key 没有意义
+	assert.Equal(t, time.Millisecond*2500, mr.TTL("tb"))
+}
+
+func TestRedisFixedWindowLimiter(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	l := NewRedisFixedWindowLimiter(rdb, time.Second, 3)
+
+	assert.Equal(t, 2, limitN(t, l, "fw", 5))
+	assert.Equal(t, time.Second, mr.TTL("fw"))
+	// 窗口过了重新计数
+	mr.FastForward(time.Second)
+	assert.Equal(t, 0, limitN(t, l, "fw", 3))
+	assert.Equal(t, 1, limitN(t, l, "fw", 1))
+}
+
+func TestRedisFixedWindowLimiter_NoTTL(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	// 之前设置过期时间失败, 留下一个永远不过期的计数器
+	require.NoError(t, mr.Set("fw", "10"))
+	l := NewRedisFixedWindowLimiter(rdb, time.Second, 3)
+	assert.Equal(t, 1, limitN(t, l, "fw", 1))
+	assert.Equal(t, time.Second, mr.TTL("fw"))
+}
+
+func TestRedisLimiter_Result(t *testing.T) {
+	testCases := []struct {
+		name    string
+		limiter func(rdb redis.Cmdable) Limiter
+		// 连续请求 4 次, 每次的结果
+		want []Result
+	}{
+		{
+			name: "滑动窗口",
+			limiter: func(rdb redis.Cmdable) Limiter {
+				return NewRedisSlidingWindowLimiter(rdb, time.Minute, 3)
+			},
+			want: []Result{
+				{Limit: 3, Remaining: 2, Reset: time.Minute},
+				{Limit: 3, Remaining: 1, Reset: time.Minute},
+				{Limit: 3, Remaining: 0, Reset: time.Minute},
+				{Limited: true, Limit: 3, Remaining: 0, Reset: time.Minute},
+			},
+		},
+		{
+			name: "令牌桶",
+			limiter: func(rdb redis.Cmdable) Limiter {
+				l := NewRedisTokenBucketLimiter(rdb, 3, time.Second, 2)
+				l.now = func() time.Time {
+					return time.UnixMilli(1_000_000)
+				}
+				return l
+			},
+			want: []Result{
+				{Limit: 3, Remaining: 2, Reset: time.Millisecond * 500},
+				{Limit: 3, Remaining: 1, Reset: time.Millisecond * 500},
+				{Limit: 3, Remaining: 0, Reset: time.Millisecond * 500},
+				{Limited: true, Limit: 3, Remaining: 0, Reset: time.Millisecond * 500},
+			},
+		},
+		{
+			name: "固定窗口",
+			limiter: func(rdb redis.Cmdable) Limiter {
+				return NewRedisFixedWindowLimiter(rdb, time.Minute, 3)
+			},
+			want: []Result{
+				{Limit: 3, Remaining: 2, Reset: time.Minute},
+				{Limit: 3, Remaining: 1, Reset: time.Minute},
+				{Limit: 3, Remaining: 0, Reset: time.Minute},
+				{Limited: true, Limit: 3, Remaining: 0, Reset: time.Minute},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, rdb := newTestRedis(t)
+			l := tc.limiter(rdb)
+			for _, want := range tc.want {
+				res, err := l.Limit(context.Background(), "key")
+				require.NoError(t, err)
+				// 滑动窗口用的是真实时间, 允许一点误差
+				assert.InDelta(t, want.Reset, res.Reset, float64(time.Millisecond*100))
+				res.Reset = want.Reset
+				assert.Equal(t, want, res)
+			}
+		})
+	}
+}
+
+func TestRedisSlidingWindowLimiter_Concurrent(t *testing.T) {
+	_, rdb := newTestRedis(t)
+	const threshold = 100
+	l := NewRedisSlidingWindowLimiter(rdb, time.Minute, threshold)
+	var passed atomic.Int64
+	var wg sync.WaitGroup
+	// 模拟多个实例同一时刻打过来, 大部分请求都在同一毫秒里面
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				res, err := l.Limit(context.Background(), "sw")
+				assert.NoError(t, err)
+				if !res.Limited {
+					passed.Add(1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(threshold), passed.Load())
+	cnt, err := rdb.ZCard(context.Background(), "sw").Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(threshold), cnt)
+}
+
+func TestRedisSlidingWindowLimiter_ServerTime(t *testing.T) {
+	mr, rdb := newTestRedis(t)
+	// Redis 的时间和本机差了一个小时, 窗口按 Redis 的时间算
+	serverNow := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	mr.SetTime(serverNow)
+	l := NewRedisSlidingWindowLimiter(rdb, time.Second, 2)
+	assert.Equal(t, 1, limitN(t, l, "sw", 3))
+	members, err := rdb.ZRangeWithScores(context.Background(), "sw", 0, -1).Result()
+	require.NoError(t, err)
+	require.Len(t, members, 2)
+	for _, m := range members {
+		assert.Equal(t, float64(serverNow.UnixMilli()), m.Score)
+	}
+	// Redis 的时间过了窗口, 额度就恢复了
+	mr.SetTime(serverNow.Add(time.Second))
+	assert.Equal(t, 0, limitN(t, l, "sw", 2))
+}
@@ -3,6 +3,7 @@ package limiter
 import (
 	"context"
 	_ "embed"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 	"time"
 )
@@ -24,6 +25,10 @@ func NewRedisSlidingWindowLimiter(cmd redis.Cmdable, interval time.Duration, rat
 	}
 }
 
-func (b *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (bool, error) {
-	return b.cmd.Eval(ctx, luaScript, []string{key}, b.interval.Milliseconds(), b.rate, time.Now().UnixMilli()).Bool()
+func (b *RedisSlidingWindowLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	vals, err := b.cmd.Eval(ctx, luaScript, []string{key}, b.interval.Milliseconds(), b.rate, uuid.New().String()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return luaResult(vals, b.rate), nil
 }
@@ -0,0 +1,40 @@
+package limiter
+
+import (
+	"context"
+	_ "embed"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+//go:embed token_bucket.lua
+var luaTokenBucket string
+
+// RedisTokenBucketLimiter 令牌桶, 每个 key 只占一个 hash, 允许最多 capacity 个请求的突发
+type RedisTokenBucketLimiter struct {
+	cmd      redis.Cmdable
+	capacity int
+	// rate 每毫秒补充的令牌数
+	rate float64
+	now  func() time.Time
+}
+
+// NewRedisTokenBucketLimiter 每 interval 补充 rate 个令牌, 桶最多放 capacity 个, 参数不是正数会 panic
+// Redis 里按毫秒算, interval 不能小于 1 毫秒
+func NewRedisTokenBucketLimiter(cmd redis.Cmdable, capacity int, interval time.Duration, rate int) *RedisTokenBucketLimiter {
+	mustValidTokenBucket(capacity, interval.Truncate(time.Millisecond), rate)
+	return &RedisTokenBucketLimiter{
+		cmd:      cmd,
+		capacity: capacity,
+		rate:     float64(rate) / float64(interval.Milliseconds()),
+		now:      time.Now,
+	}
+}
+
+func (b *RedisTokenBucketLimiter) Limit(ctx context.Context, key string) (Result, error) {
+	vals, err := b.cmd.Eval(ctx, luaTokenBucket, []string{key}, b.capacity, b.rate, b.now().UnixMilli()).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+	return luaResult(vals, b.capacity), nil
+}
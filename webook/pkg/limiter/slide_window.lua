@@ -14,19 +14,32 @@ local key = KEYS[1]
 local window = tonumber(ARGV[1])
 -- 阈值
 local threshold = tonumber( ARGV[2])
-local now = tonumber(ARGV[3])
+-- 每个请求唯一的 member, 同一毫秒里面的请求不会被合并
+local member = ARGV[3]
+-- 用 Redis 的时间, 不受各个实例时钟偏差的影响
+-- Redis 5 之前要先打开按命令复制, 才能在 TIME 之后写数据
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
 -- 窗口的起始时间
 local min = now - window
 
 redis.call('ZREMRANGEBYSCORE', key, '-inf', min)
 local cnt = redis.call('ZCOUNT', key, '-inf', '+inf')
 -- local cnt = redis.call('ZCOUNT', key, min, '+inf')
+local limited = 0
 if cnt >= threshold then
     -- 执行限流
-    return "true"
+    limited = 1
 else
-    -- 把 score 和 member 都设置成 now
-    redis.call('ZADD', key, now, now)
+    redis.call('ZADD', key, now, member)
     redis.call('PEXPIRE', key, window)
-    return "false"
-end
\ No newline at end of file
+    cnt = cnt + 1
+end
+-- 最早的那个请求滑出窗口之后就恢复一个额度
+local reset = 0
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] ~= nil then
+    reset = tonumber(oldest[2]) + window - now
+end
+return {limited, math.max(0, threshold - cnt), reset}
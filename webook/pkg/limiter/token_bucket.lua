@@ -0,0 +1,35 @@
+-- 令牌桶, 桶里存 tokens 和上一次补充的时间 ts
+local key = KEYS[1]
+-- 桶的容量, 也就是允许的突发量
+local capacity = tonumber(ARGV[1])
+-- 每毫秒补充的令牌数
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+
+local bucket = redis.call('HMGET', key, 'tokens', 'ts')
+local tokens = tonumber(bucket[1])
+local ts = tonumber(bucket[2])
+if tokens == nil or ts == nil then
+    -- 新的桶是满的
+    tokens = capacity
+    ts = now
+end
+-- 多个实例的时钟不一致, 时间倒退了就当没过去
+local elapsed = math.max(0, now - ts)
+tokens = math.min(capacity, tokens + elapsed * rate)
+
+local limited = 1
+if tokens >= 1 then
+    limited = 0
+    tokens = tokens - 1
+end
+redis.call('HSET', key, 'tokens', tostring(tokens), 'ts', tostring(math.max(now, ts)))
+-- 补满之后这个桶和不存在是一样的
+redis.call('PEXPIRE', key, math.ceil(capacity / rate))
+
+-- 补出下一个整的令牌要多久
+local reset = 0
+if tokens < capacity then
+    reset = math.ceil((math.floor(tokens) + 1 - tokens) / rate)
+end
+return {limited, math.floor(tokens), reset}
@@ -1,7 +1,31 @@
 package limiter
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type Limiter interface {
-	Limit(ctx context.Context, key string) (bool, error)
+	Limit(ctx context.Context, key string) (Result, error)
+}
+
+// Result 一次限流检查的结果, 给客户端返回 X-RateLimit-* 这些头用
+type Result struct {
+	Limited bool
+	// Limit 窗口内允许的请求数, 令牌桶是桶的容量; 0 表示不知道, 比如降级的时候
+	Limit int
+	// Remaining 这次之后还剩下的额度
+	Remaining int
+	// Reset 多久之后至少恢复一个额度, 被限流的时候就是要等多久才能重试
+	Reset time.Duration
+}
+
+// luaResult 几个 lua 脚本都返回 {是否限流, 剩余额度, 多少毫秒之后恢复一个额度}
+func luaResult(vals []int64, limit int) Result {
+	return Result{
+		Limited:   vals[0] == 1,
+		Limit:     limit,
+		Remaining: int(vals[1]),
+		Reset:     time.Duration(vals[2]) * time.Millisecond,
+	}
 }
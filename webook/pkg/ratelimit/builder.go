@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 )
 
 type Builder struct {
@@ -27,17 +29,30 @@ func (b *Builder) Prefix(prefix string) *Builder {
 
 func (b *Builder) Build() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		limited, err := b.limiter.Limit(ctx, fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP()))
+		res, err := b.limiter.Limit(ctx, fmt.Sprintf("%s:%s", b.prefix, ctx.ClientIP()))
 		if err != nil {
 			log.Println(err)
 			ctx.AbortWithStatus(http.StatusInternalServerError)
 			return
 		}
-		if limited {
-			log.Println(err)
+		setHeaders(ctx, res)
+		if res.Limited {
 			ctx.AbortWithStatus(http.StatusTooManyRequests)
 			return
 		}
 		ctx.Next()
 	}
 }
+
+// setHeaders 降级的时候不知道额度, 就只在被限流的时候告诉客户端多久之后重试
+func setHeaders(ctx *gin.Context, res limiter.Result) {
+	if res.Limit > 0 {
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	}
+	if res.Limited {
+		// Retry-After 是整数秒, 向上取整, 至少 1 秒
+		secs := int64(math.Ceil(res.Reset.Seconds()))
+		ctx.Header("Retry-After", strconv.FormatInt(max(secs, 1), 10))
+	}
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"basic_go/webook/pkg/limiter"
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuilder(t *testing.T) {
+	testCases := []struct {
+		name           string
+		limiter        func(rdb redis.Cmdable) limiter.Limiter
+		wantRetryAfter string
+	}{
+		{
+			name: "令牌桶",
+			limiter: func(rdb redis.Cmdable) limiter.Limiter {
+				return limiter.NewRedisTokenBucketLimiter(rdb, 2, time.Minute, 1)
+			},
+			wantRetryAfter: "60",
+		},
+		{
+			name: "固定窗口",
+			limiter: func(rdb redis.Cmdable) limiter.Limiter {
+				return limiter.NewRedisFixedWindowLimiter(rdb, time.Minute, 2)
+			},
+			wantRetryAfter: "60",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			server := gin.New()
+			server.Use(NewBuilder(tc.limiter(rdb)).Build())
+			server.GET("/hello", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			codes := make([]int, 0, 3)
+			remaining := make([]string, 0, 3)
+			var resp *httptest.ResponseRecorder
+			for i := 0; i < 3; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+				req.RemoteAddr = "10.0.0.1:12345"
+				resp = httptest.NewRecorder()
+				server.ServeHTTP(resp, req)
+				codes = append(codes, resp.Code)
+				remaining = append(remaining, resp.Header().Get("X-RateLimit-Remaining"))
+				assert.Equal(t, "2", resp.Header().Get("X-RateLimit-Limit"))
+			}
+			assert.Equal(t, []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}, codes)
+			assert.Equal(t, []string{"1", "0", "0"}, remaining)
+			assert.Equal(t, tc.wantRetryAfter, resp.Header().Get("Retry-After"))
+			// 按 IP 限流
+			assert.True(t, mr.Exists("ip-limiter:10.0.0.1"))
+		})
+	}
+}
+
+// degradedLimiter 降级的时候不知道额度
+type degradedLimiter struct{}
+
+func (degradedLimiter) Limit(ctx context.Context, key string) (limiter.Result, error) {
+	return limiter.Result{Limited: true, Reset: time.Millisecond * 300}, nil
+}
+
+func TestBuilder_Degraded(t *testing.T) {
+	server := gin.New()
+	server.Use(NewBuilder(degradedLimiter{}).Build())
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	resp := httptest.NewRecorder()
+	server.ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusTooManyRequests, resp.Code)
+	assert.Empty(t, resp.Header().Get("X-RateLimit-Limit"))
+	// 不到 1 秒也要至少等 1 秒
+	assert.Equal(t, "1", resp.Header().Get("Retry-After"))
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"basic_go/webook/pkg/limiter"
+	"basic_go/webook/pkg/route"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// KeyFunc 从请求里面取出限流的对象, 返回空字符串表示这条规则不管这个请求, 比如没登录就没有 uid
+type KeyFunc func(ctx *gin.Context) string
+
+// KeyByIP 用的是 gin 的 ClientIP, 引擎要用 SetTrustedProxies 设置好前面的代理,
+// 不然 gin 信任所有人带的 X-Forwarded-For, 每次换一个就绕过去了
+func KeyByIP() KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.ClientIP()
+	}
+}
+
+func KeyByHeader(name string) KeyFunc {
+	return func(ctx *gin.Context) string {
+		return ctx.GetHeader(name)
+	}
+}
+
+// KeyByUid uid 怎么从登录态里面拿由调用方决定, 拿不到就不限
+func KeyByUid(uid func(ctx *gin.Context) (int64, bool)) KeyFunc {
+	return func(ctx *gin.Context) string {
+		id, ok := uid(ctx)
+		if !ok {
+			return ""
+		}
+		return strconv.FormatInt(id, 10)
+	}
+}
+
+// Rule 一条限流规则, 窗口、阈值和算法都由 Limiter 决定
+type Rule struct {
+	// Name 拼在 key 里面, 不同规则的计数互不影响
+	Name string
+	// Routes 写法见 route.Rule, 比如 "POST /users/login"
+	Routes  route.Rules
+	Key     KeyFunc
+	Limiter limiter.Limiter
+}
+
+// RulesBuilder 一个请求匹配上的所有规则都要检查, 任意一条触发了就拒绝
+// 比如全局按 IP 限流之外, 登录再单独加一条更严格的
+type RulesBuilder struct {
+	prefix string
+	rules  []Rule
+}
+
+func NewRulesBuilder(rules ...Rule) *RulesBuilder {
+	return &RulesBuilder{
+		prefix: "rule-limiter",
+		rules:  rules,
+	}
+}
+
+func (b *RulesBuilder) Prefix(prefix string) *RulesBuilder {
+	b.prefix = prefix
+	return b
+}
+
+func (b *RulesBuilder) Build() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// 响应头里面放额度最紧张的那条规则
+		var tightest *limiter.Result
+		for _, r := range b.rules {
+			if !r.Routes.Match(ctx.Request.Method, ctx.Request.URL.Path) {
+				continue
+			}
+			key := r.Key(ctx)
+			if key == "" {
+				continue
+			}
+			res, err := r.Limiter.Limit(ctx, fmt.Sprintf("%s:%s:%s", b.prefix, r.Name, key))
+			if err != nil {
+				log.Println("限流规则出错", r.Name, err)
+				ctx.AbortWithStatus(http.StatusInternalServerError)
+				return
+			}
+			if res.Limited {
+				setHeaders(ctx, res)
+				ctx.AbortWithStatus(http.StatusTooManyRequests)
+				return
+			}
+			if res.Limit > 0 && (tightest == nil || res.Remaining < tightest.Remaining) {
+				tightest = &res
+			}
+		}
+		if tightest != nil {
+			setHeaders(ctx, *tightest)
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"basic_go/webook/pkg/limiter"
+	"basic_go/webook/pkg/route"
+	"context"
+	"errors"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type errLimiter struct{}
+
+func (errLimiter) Limit(ctx context.Context, key string) (limiter.Result, error) {
+	return limiter.Result{}, errors.New("redis error")
+}
+
+func TestRulesBuilder(t *testing.T) {
+	type request struct {
+		method string
+		path   string
+		ip     string
+		uid    int64
+		device string
+		// wantCode 和额度最紧张的那条规则剩下的额度
+		wantCode      int
+		wantRemaining string
+	}
+	testCases := []struct {
+		name     string
+		rules    func(rdb redis.Cmdable) []Rule
+		requests []request
+	}{
+		{
+			name: "登录比其他接口更严格",
+			rules: func(rdb redis.Cmdable) []Rule {
+				return []Rule{
+					{
+						Name:    "global",
+						Routes:  route.Rules{route.MustParseRule("/**")},
+						Key:     KeyByIP(),
+						Limiter: limiter.NewRedisTokenBucketLimiter(rdb, 10, time.Minute, 10),
+					},
+					{
+						Name:    "login",
+						Routes:  route.Rules{route.MustParseRule("POST /users/login")},
+						Key:     KeyByIP(),
+						Limiter: limiter.NewRedisFixedWindowLimiter(rdb, time.Minute, 1),
+					},
+				}
+			},
+			requests: []request{
+				{method: http.MethodPost, path: "/users/login", ip: "10.0.0.1", wantCode: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodPost, path: "/users/login", ip: "10.0.0.1", wantCode: http.StatusTooManyRequests, wantRemaining: "0"},
+				// 换个 IP 不受影响
+				{method: http.MethodPost, path: "/users/login", ip: "10.0.0.2", wantCode: http.StatusOK, wantRemaining: "0"},
+				// 其他接口只有全局的规则, 前面已经用掉了 2 个
+				{method: http.MethodGet, path: "/users/profile", ip: "10.0.0.1", wantCode: http.StatusOK, wantRemaining: "7"},
+				// method 不匹配
+				{method: http.MethodGet, path: "/users/login", ip: "10.0.0.1", wantCode: http.StatusOK, wantRemaining: "6"},
+			},
+		},
+		{
+			name: "按 uid 限流, 没登录的不限",
+			rules: func(rdb redis.Cmdable) []Rule {
+				return []Rule{
+					{
+						Name:   "profile",
+						Routes: route.Rules{route.MustParseRule("GET /users/profile")},
+						Key: KeyByUid(func(ctx *gin.Context) (int64, bool) {
+							uid := ctx.GetInt64("uid")
+							return uid, uid > 0
+						}),
+						Limiter: limiter.NewRedisSlidingWindowLimiter(rdb, time.Minute, 1),
+					},
+				}
+			},
+			requests: []request{
+				{method: http.MethodGet, path: "/users/profile", ip: "10.0.0.1", uid: 1, wantCode: http.StatusOK, wantRemaining: "0"},
+				// 换了 IP 也是同一个用户
+				{method: http.MethodGet, path: "/users/profile", ip: "10.0.0.2", uid: 1, wantCode: http.StatusTooManyRequests, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/users/profile", ip: "10.0.0.1", uid: 2, wantCode: http.StatusOK, wantRemaining: "0"},
+				{method: http.MethodGet, path: "/users/profile", ip: "10.0.0.1", wantCode: http.StatusOK},
+			},
+		},
+		{
+			name: "按请求头限流",
+			rules: func(rdb redis.Cmdable) []Rule {
+				return []Rule{
+					{
+						Name:    "device",
+						Routes:  route.Rules{route.MustParseRule("POST /users/*/code/send")},
+						Key:     KeyByHeader("X-Device-Id"),
+						Limiter: limiter.NewRedisFixedWindowLimiter(rdb, time.Minute, 1),
+					},
+				}
+			},
+			requests: []request{
+				{method: http.MethodPost, path: "/users/login_sms/code/send", device: "d1", wantCode: http.StatusOK, wantRemaining: "0"},
+				// 同一个规则下面的不同接口共享额度
+				{method: http.MethodPost, path: "/users/login_email/code/send", device: "d1", wantCode: http.StatusTooManyRequests, wantRemaining: "0"},
+				{method: http.MethodPost, path: "/users/login_sms/code/send", device: "d2", wantCode: http.StatusOK, wantRemaining: "0"},
+			},
+		},
+		{
+			name: "限流器出错",
+			rules: func(rdb redis.Cmdable) []Rule {
+				return []Rule{
+					{
+						Name:    "global",
+						Routes:  route.Rules{route.MustParseRule("/**")},
+						Key:     KeyByIP(),
+						Limiter: errLimiter{},
+					},
+				}
+			},
+			requests: []request{
+				{method: http.MethodGet, path: "/hello", ip: "10.0.0.1", wantCode: http.StatusInternalServerError},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			server := gin.New()
+			// 模拟登录校验的 middleware
+			server.Use(func(ctx *gin.Context) {
+				if uid, err := strconv.ParseInt(ctx.GetHeader("uid"), 10, 64); err == nil {
+					ctx.Set("uid", uid)
+				}
+			})
+			server.Use(NewRulesBuilder(tc.rules(rdb)...).Build())
+			server.Any("/*path", func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			for _, r := range tc.requests {
+				req := httptest.NewRequest(r.method, r.path, nil)
+				req.RemoteAddr = r.ip + ":12345"
+				if r.uid > 0 {
+					req.Header.Set("uid", strconv.FormatInt(r.uid, 10))
+				}
+				if r.device != "" {
+					req.Header.Set("X-Device-Id", r.device)
+				}
+				resp := httptest.NewRecorder()
+				server.ServeHTTP(resp, req)
+				assert.Equal(t, r.wantCode, resp.Code, r.path)
+				assert.Equal(t, r.wantRemaining, resp.Header().Get("X-RateLimit-Remaining"), r.path)
+			}
+		})
+	}
+}
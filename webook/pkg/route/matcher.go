@@ -0,0 +1,85 @@
+package route
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Rule 匹配一类请求, 写法是 "[METHOD ]PATTERN":
+//
+//	/users/login        精确匹配, 所有 method
+//	GET /articles/*     * 匹配一段路径, 只匹配 GET
+//	/admin/**           以 /admin/ 开头的所有路径
+type Rule struct {
+	method  string
+	pattern string
+	// prefix 不为空说明是 /** 结尾的前缀匹配
+	prefix string
+}
+
+func ParseRule(s string) (Rule, error) {
+	var r Rule
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		r.pattern = fields[0]
+	case 2:
+		r.method, r.pattern = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return Rule{}, fmt.Errorf("非法的路由规则 %q", s)
+	}
+	// 少写了开头的 / 也能匹配上
+	if !strings.HasPrefix(r.pattern, "/") {
+		r.pattern = "/" + r.pattern
+	}
+	if strings.HasSuffix(r.pattern, "/**") {
+		r.prefix = strings.TrimSuffix(r.pattern, "**")
+		return r, nil
+	}
+	if _, err := path.Match(r.pattern, ""); err != nil {
+		return Rule{}, fmt.Errorf("非法的路由规则 %q: %w", s, err)
+	}
+	return r, nil
+}
+
+func MustParseRule(s string) Rule {
+	r, err := ParseRule(s)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r Rule) Match(method, p string) bool {
+	if r.method != "" && r.method != method {
+		return false
+	}
+	if r.prefix != "" {
+		return strings.HasPrefix(p, r.prefix) || p == strings.TrimSuffix(r.prefix, "/")
+	}
+	if r.pattern == p {
+		return true
+	}
+	ok, _ := path.Match(r.pattern, p)
+	return ok
+}
+
+func (r Rule) String() string {
+	if r.method == "" {
+		return r.pattern
+	}
+	return r.method + " " + r.pattern
+}
+
+// Rules 任意一条规则匹配上就算匹配
+type Rules []Rule
+
+func (rs Rules) Match(method, p string) bool {
+	for _, r := range rs {
+		if r.Match(method, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package route
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"testing"
+)
+
+func TestRule_Match(t *testing.T) {
+	testCases := []struct {
+		name   string
+		rule   string
+		method string
+		path   string
+		want   bool
+	}{
+		{name: "精确匹配", rule: "/users/login", method: http.MethodPost, path: "/users/login", want: true},
+		{name: "精确不匹配", rule: "/users/login", method: http.MethodPost, path: "/users/login_sms", want: false},
+		{name: "缺少开头的斜杠", rule: "oauth2/wechat/callback", method: http.MethodGet, path: "/oauth2/wechat/callback", want: true},
+		{name: "glob 匹配一段", rule: "GET /articles/*", method: http.MethodGet, path: "/articles/123", want: true},
+		{name: "glob 不跨段", rule: "GET /articles/*", method: http.MethodGet, path: "/articles/123/comments", want: false},
+		{name: "method 不对", rule: "GET /articles/*", method: http.MethodPost, path: "/articles/123", want: false},
+		{name: "method 小写", rule: "get /articles/*", method: http.MethodGet, path: "/articles/123", want: true},
+		{name: "前缀匹配", rule: "/admin/**", method: http.MethodGet, path: "/admin/users/1/ban", want: true},
+		{name: "前缀本身", rule: "/admin/**", method: http.MethodGet, path: "/admin", want: true},
+		{name: "前缀不匹配", rule: "/admin/**", method: http.MethodGet, path: "/administrator", want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := ParseRule(tc.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, r.Match(tc.method, tc.path))
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	_, err := ParseRule("GET /a/[ POST")
+	assert.Error(t, err)
+	_, err = ParseRule("/a/[")
+	assert.Error(t, err)
+}